@@ -0,0 +1,174 @@
+package ddb
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/seill/util"
+)
+
+const maxBatchGetItems = 100
+
+var ErrUnprocessedItems = errors.New("unprocessed items remain")
+
+// BatchGetItem reads base table items by PK/SK. Items are returned in the order
+// of keys; keys without an item are skipped.
+func (r *Ddb) BatchGetItem(keys []Key, getOption ...GetOption) (items []map[string]types.AttributeValue, err error) {
+	r, end := r.begin("BatchGetItem")
+	defer func() { end(err) }()
+
+	var option = mergeGetOption(getOption)
+	var expressionAttributeNames = map[string]string{}
+	var projectionExpression *string
+	var requestKeys []map[string]types.AttributeValue
+	var found = map[string]map[string]types.AttributeValue{}
+	var order []string
+
+	if 0 < len(option.Projection) {
+		// keys are needed to put the items back in order
+		projectionExpression, err = buildProjection(slices.Concat(r.deletionProjection(r.expiryProjection(option.Projection), option.IncludeDeleted), baseKeyProjection), expressionAttributeNames)
+		if err != nil {
+			return
+		}
+	}
+
+	consistent, err := consistentRead(option.ConsistentRead, nil)
+	if err != nil {
+		return
+	}
+
+	for _, key := range keys {
+		var av map[string]types.AttributeValue
+
+		if nil != key.IndexName || nil == key.PK {
+			err = fmt.Errorf("invalid key: BatchGetItem needs base table keys (%s)", util.StructToString(key))
+			return
+		}
+
+		av, err = attributevalue.MarshalMap(Key{PK: key.PK, SK: key.SK})
+		if err != nil {
+			return
+		}
+
+		id := batchKeyString(key)
+		order = append(order, id)
+		if _, ok := found[id]; !ok {
+			found[id] = nil
+			requestKeys = append(requestKeys, av)
+		}
+	}
+
+	for start := 0; start < len(requestKeys); start += maxBatchGetItems {
+		var end = min(start+maxBatchGetItems, len(requestKeys))
+
+		keysAndAttributes := types.KeysAndAttributes{
+			Keys:                 requestKeys[start:end],
+			ProjectionExpression: projectionExpression,
+			ConsistentRead:       consistent,
+		}
+		if 0 < len(expressionAttributeNames) {
+			keysAndAttributes.ExpressionAttributeNames = expressionAttributeNames
+		}
+
+		requestItems := map[string]types.KeysAndAttributes{r.tableName: keysAndAttributes}
+		policy := r.unprocessedPolicy()
+
+		for retry := 0; 0 < len(requestItems); {
+			var output *dynamodb.BatchGetItemOutput
+
+			output, err = invoke(r, &dynamodb.BatchGetItemInput{RequestItems: requestItems}, r.dynamoDb.BatchGetItem)
+			if err != nil {
+				return
+			}
+
+			for _, item := range output.Responses[r.tableName] {
+				found[batchItemKeyString(item)] = item
+			}
+
+			requestItems = output.UnprocessedKeys
+			if 0 == len(requestItems) {
+				break
+			}
+
+			// back off while DynamoDB returns keys unprocessed without progress
+			if 0 < len(output.Responses[r.tableName]) {
+				retry = 0
+			} else if retry++; retry >= policy.MaxAttempts {
+				err = fmt.Errorf("%w (%d keys after %d attempts)", ErrUnprocessedItems, len(requestItems[r.tableName].Keys), retry)
+				return
+			}
+			if err = r.sleep(r.context(), policy.backoff(retry)); err != nil {
+				return
+			}
+		}
+	}
+
+	for _, id := range order {
+		if item := found[id]; nil != item {
+			items = append(items, item)
+		}
+	}
+	items = r.undeleted(r.unexpired(items, option.Projection), option.Projection, option.IncludeDeleted)
+	withoutBaseKeys(items, option.Projection)
+
+	return
+}
+
+// withoutBaseKeys drops from items the PK and SK that only the ordering asked for.
+func withoutBaseKeys(items []map[string]types.AttributeValue, projection []string) {
+	if 0 == len(projection) {
+		return
+	}
+
+	for _, key := range baseKeyProjection {
+		if slices.Contains(projection, key) {
+			continue
+		}
+		for _, item := range items {
+			delete(item, key)
+		}
+	}
+}
+
+func batchKeyString(key Key) string {
+	return fmt.Sprintf("%s\x00%s", aws.ToString(key.PK), aws.ToString(key.SK))
+}
+
+func batchItemKeyString(item map[string]types.AttributeValue) string {
+	return batchKeyString(baseKeyOf(item))
+}
+
+var baseKeyProjection = []string{"PK", "SK"}
+
+func baseKeyOf(item map[string]types.AttributeValue) (key Key) {
+	if pk, ok := item["PK"].(*types.AttributeValueMemberS); ok {
+		key.PK = aws.String(pk.Value)
+	}
+	if sk, ok := item["SK"].(*types.AttributeValueMemberS); ok {
+		key.SK = aws.String(sk.Value)
+	}
+
+	return
+}
+
+// fetchFromBase replaces index items by the full base table items they point to.
+func (r *Ddb) fetchFromBase(indexItems []map[string]types.AttributeValue, projection []string, includeDeleted bool) (items []map[string]types.AttributeValue, err error) {
+	var keys = make([]Key, len(indexItems))
+
+	if 0 == len(indexItems) {
+		return
+	}
+
+	for i, item := range indexItems {
+		keys[i] = baseKeyOf(item)
+	}
+
+	items, err = r.BatchGetItem(keys, GetOption{Projection: projection, IncludeDeleted: includeDeleted})
+
+	return
+}
@@ -0,0 +1,165 @@
+package ddb
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Capacity aggregates consumed capacity units. Indexes is only filled when
+// INDEXES is requested.
+type Capacity struct {
+	CapacityUnits      float64            `json:"capacityUnits"`
+	ReadCapacityUnits  float64            `json:"readCapacityUnits"`
+	WriteCapacityUnits float64            `json:"writeCapacityUnits"`
+	Indexes            map[string]float64 `json:"indexes,omitempty"`
+}
+
+func (c *Capacity) add(consumed []types.ConsumedCapacity) {
+	for _, capacity := range consumed {
+		c.CapacityUnits += aws.ToFloat64(capacity.CapacityUnits)
+		c.ReadCapacityUnits += aws.ToFloat64(capacity.ReadCapacityUnits)
+		c.WriteCapacityUnits += aws.ToFloat64(capacity.WriteCapacityUnits)
+
+		for name, index := range capacity.GlobalSecondaryIndexes {
+			c.addIndex(name, index)
+		}
+		for name, index := range capacity.LocalSecondaryIndexes {
+			c.addIndex(name, index)
+		}
+	}
+}
+
+func (c *Capacity) addIndex(name string, index types.Capacity) {
+	if nil == c.Indexes {
+		c.Indexes = map[string]float64{}
+	}
+	c.Indexes[name] += aws.ToFloat64(index.CapacityUnits)
+}
+
+func (c *Capacity) merge(other Capacity) {
+	c.CapacityUnits += other.CapacityUnits
+	c.ReadCapacityUnits += other.ReadCapacityUnits
+	c.WriteCapacityUnits += other.WriteCapacityUnits
+
+	for name, units := range other.Indexes {
+		if nil == c.Indexes {
+			c.Indexes = map[string]float64{}
+		}
+		c.Indexes[name] += units
+	}
+}
+
+// CapacityAccumulator collects the capacity consumed by every call made with a
+// context carrying it, see ContextWithCapacityAccumulator.
+type CapacityAccumulator struct {
+	mu          sync.Mutex
+	total       Capacity
+	byOperation map[string]Capacity
+}
+
+func NewCapacityAccumulator() *CapacityAccumulator {
+	return &CapacityAccumulator{
+		byOperation: map[string]Capacity{},
+	}
+}
+
+func (a *CapacityAccumulator) add(operation string, capacity Capacity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.total.merge(capacity)
+
+	byOperation := a.byOperation[operation]
+	byOperation.merge(capacity)
+	a.byOperation[operation] = byOperation
+}
+
+func (a *CapacityAccumulator) Total() (total Capacity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	total.merge(a.total)
+
+	return
+}
+
+// ByOperation returns the consumed capacity per DynamoDB operation (GetItem, Query, ...).
+func (a *CapacityAccumulator) ByOperation() (byOperation map[string]Capacity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	byOperation = make(map[string]Capacity, len(a.byOperation))
+	for operation, capacity := range a.byOperation {
+		var c Capacity
+
+		c.merge(capacity)
+		byOperation[operation] = c
+	}
+
+	return
+}
+
+type capacityAccumulatorKey struct{}
+
+func ContextWithCapacityAccumulator(ctx context.Context, accumulator *CapacityAccumulator) context.Context {
+	return context.WithValue(ctx, capacityAccumulatorKey{}, accumulator)
+}
+
+func CapacityAccumulatorFromContext(ctx context.Context) (accumulator *CapacityAccumulator) {
+	accumulator, _ = ctx.Value(capacityAccumulatorKey{}).(*CapacityAccumulator)
+
+	return
+}
+
+// CapacityHook receives the capacity consumed by each DynamoDB call.
+type CapacityHook func(ctx context.Context, operation string, capacity Capacity)
+
+// SetReturnConsumedCapacity requests TOTAL or INDEXES consumed capacity on every call.
+func (r *Ddb) SetReturnConsumedCapacity(mode types.ReturnConsumedCapacity) *Ddb {
+	r.returnConsumedCapacity = mode
+
+	return r
+}
+
+func (r *Ddb) SetCapacityHook(hook CapacityHook) *Ddb {
+	r.capacityHook = hook
+
+	return r
+}
+
+func (r *Ddb) reportCapacity(ctx context.Context, operation string, consumed []types.ConsumedCapacity) {
+	var capacity Capacity
+	var accumulator = CapacityAccumulatorFromContext(ctx)
+
+	if 0 == len(consumed) || (nil == accumulator && nil == r.capacityHook) {
+		return
+	}
+
+	capacity.add(consumed)
+
+	if nil != accumulator {
+		accumulator.add(operation, capacity)
+	}
+	if nil != r.capacityHook {
+		r.capacityHook(ctx, operation, capacity)
+	}
+}
+
+// operationName derives the DynamoDB operation from its input type.
+func operationName(input interface{}) string {
+	var t = reflect.TypeOf(input)
+
+	for nil != t && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if nil == t {
+		return ""
+	}
+
+	return strings.TrimSuffix(t.Name(), "Input")
+}
@@ -0,0 +1,39 @@
+package ddb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestReportCapacity(t *testing.T) {
+	var hooked []string
+
+	r := New(nil, "table").SetCapacityHook(func(ctx context.Context, operation string, capacity Capacity) {
+		hooked = append(hooked, operation)
+	})
+	accumulator := NewCapacityAccumulator()
+	ctx := ContextWithCapacityAccumulator(context.TODO(), accumulator)
+
+	r.reportCapacity(ctx, operationName(&dynamodb.QueryInput{}), consumedCapacityOf(&dynamodb.QueryOutput{ConsumedCapacity: &types.ConsumedCapacity{
+		CapacityUnits:          aws.Float64(2),
+		GlobalSecondaryIndexes: map[string]types.Capacity{GSI1: {CapacityUnits: aws.Float64(1.5)}},
+	}}))
+	r.reportCapacity(ctx, operationName(&dynamodb.BatchGetItemInput{}), consumedCapacityOf(&dynamodb.BatchGetItemOutput{ConsumedCapacity: []types.ConsumedCapacity{
+		{CapacityUnits: aws.Float64(1)},
+		{CapacityUnits: aws.Float64(0.5)},
+	}}))
+
+	if total := accumulator.Total(); 3.5 != total.CapacityUnits || 1.5 != total.Indexes[GSI1] {
+		t.Errorf("unexpected total: %+v", total)
+	}
+	if byOperation := accumulator.ByOperation(); 1.5 != byOperation["BatchGetItem"].CapacityUnits || 2 != byOperation["Query"].CapacityUnits {
+		t.Errorf("unexpected capacity by operation: %+v", byOperation)
+	}
+	if 2 != len(hooked) || "Query" != hooked[0] {
+		t.Errorf("unexpected hook calls: %v", hooked)
+	}
+}
@@ -0,0 +1,53 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// stubClient answers queries from a fixed list of pages.
+type stubClient struct {
+	Client
+	pages   []*dynamodb.QueryOutput
+	queries []*dynamodb.QueryInput
+}
+
+func (c *stubClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.QueryOutput, err error) {
+	c.queries = append(c.queries, params)
+	output = c.pages[0]
+	c.pages = c.pages[1:]
+	return
+}
+
+func TestClientInjection(t *testing.T) {
+	item := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "USER#1"}}
+	client := &stubClient{pages: []*dynamodb.QueryOutput{
+		{LastEvaluatedKey: map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "x"}}},
+		{Items: []map[string]types.AttributeValue{item}},
+	}}
+
+	got, err := New(client, "table").GetItem(Key{PK: aws.String("USER"), IndexName: aws.String(GSI1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "USER#1" != got["PK"].(*types.AttributeValueMemberS).Value || 2 != len(client.queries) {
+		t.Errorf("expected the item from the second page, got %v after %d queries", got, len(client.queries))
+	}
+
+	client.pages = []*dynamodb.QueryOutput{{Items: []map[string]types.AttributeValue{item, item}}}
+	_, err = New(client, "table").GetUniqueItem(Key{PK: aws.String("USER"), IndexName: aws.String(GSI1)})
+	var ambiguous *AmbiguousItemError
+	if !errors.As(err, &ambiguous) || !errors.Is(err, ErrAmbiguousItem) {
+		t.Errorf("expected AmbiguousItemError, got %v", err)
+	}
+
+	client.pages = []*dynamodb.QueryOutput{{}}
+	if _, _, err = New(client, "table").GetListItem(Key{PK: aws.String("USER")}, "", QueryOption{}); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package ddb
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Count counts the items matching key and the filters of queryOption with
+// Select COUNT, following every page. Order, Projection and PageSize are ignored.
+func (r *Ddb) Count(key Key, queryOption QueryOption) (count int64, scannedCount int64, err error) {
+	r, end := r.begin("Count")
+	defer func() { end(err) }()
+
+	var input *dynamodb.QueryInput
+
+	input, err = r.buildCountInput(key, queryOption)
+	if err != nil {
+		return
+	}
+
+	for {
+		var output *dynamodb.QueryOutput
+
+		output, err = invoke(r, input, r.dynamoDb.Query)
+		if err != nil {
+			return
+		}
+
+		count += int64(output.Count)
+		scannedCount += int64(output.ScannedCount)
+
+		if nil == output.LastEvaluatedKey {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+
+	return
+}
+
+func (r *Ddb) buildCountInput(key Key, queryOption QueryOption) (input *dynamodb.QueryInput, err error) {
+	queryOption.Order = nil
+	queryOption.Projection = nil
+
+	input, err = r.buildQueryInput(key, "", queryOption)
+	if err != nil {
+		return
+	}
+
+	input.Select = types.SelectCount
+	input.Limit = nil
+
+	return
+}
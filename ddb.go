@@ -3,8 +3,11 @@ package ddb
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -44,6 +47,10 @@ type DynamoDbMetaData struct {
 	GSI5SK           *string    `json:"-" dynamodbav:",omitempty"`
 	CreatedTimestamp *time.Time `json:",omitempty" dynamodbav:",omitempty"`
 	UpdatedTimestamp *time.Time `json:",omitempty" dynamodbav:",omitempty"`
+	// ExpiresAt is the time to live of the item, stored in epoch seconds.
+	ExpiresAt *time.Time `json:",omitempty" dynamodbav:",omitempty,unixtime"`
+	// DeletedTimestamp is set by DeleteItem in soft delete mode.
+	DeletedTimestamp *time.Time `json:",omitempty" dynamodbav:",omitempty"`
 }
 
 func Marshal(m IDynamoDbRecord) (data []byte, err error) {
@@ -67,24 +74,66 @@ type QueryOptionPage struct {
 }
 
 type QueryOption struct {
+	// Filter is the legacy filter map. Keywords are marshalled by their Go type,
+	// so a number sent in a string needs "valueType": "N".
 	Filter           map[string]interface{} `json:"filter"`
+	Where            *FilterExpression      `json:"where"`
 	Order            []QueryOptionOrder     `json:"order"`
 	ScanIndexForward *bool                  `json:"scanIndexForward"`
-	Page             *QueryOptionPage       `json:"page" validate:"required"`
+	// InMemorySort sorts every matching item in memory, returning the first
+	// Page.PageSize of them and no LastEvaluatedKey.
+	InMemorySort   bool             `json:"inMemorySort"`
+	Projection     []string         `json:"projection"`
+	ConsistentRead bool             `json:"consistentRead"`
+	FetchFromBase  bool             `json:"fetchFromBase"`
+	IncludeDeleted bool             `json:"includeDeleted"`
+	Page           *QueryOptionPage `json:"page" validate:"required"`
 }
 
 type Ddb struct {
-	dynamoDb  *dynamodb.Client
-	tableName string
+	dynamoDb               Client
+	tableName              string
+	ctx                    context.Context
+	indexOrderFields       map[string]string
+	maxInMemorySortItems   int
+	retryPolicy            *RetryPolicy
+	rateLimiter            *RateLimiter
+	returnConsumedCapacity types.ReturnConsumedCapacity
+	capacityHook           CapacityHook
+	middlewares            []Middleware
+	sleeper                func(ctx context.Context, delay time.Duration) error
+	clock                  func() time.Time
+	tableSchema            *TableSchema
+	hideExpired            bool
+	softDelete             *SoftDeletePolicy
+	entityRegistry         *EntityRegistry
+	call                   *Call
 }
 
-func New(dynamoDb *dynamodb.Client, tableName string) *Ddb {
+func New(dynamoDb Client, tableName string) *Ddb {
 	return &Ddb{
-		dynamoDb:  dynamoDb,
-		tableName: tableName,
+		dynamoDb:             dynamoDb,
+		tableName:            tableName,
+		maxInMemorySortItems: DefaultMaxInMemorySortItems,
 	}
 }
 
+// SetClock replaces time.Now for the timestamps r writes, so that requests can
+// be reproduced exactly.
+func (r *Ddb) SetClock(now func() time.Time) *Ddb {
+	r.clock = now
+
+	return r
+}
+
+func (r *Ddb) now() time.Time {
+	if nil == r.clock {
+		return time.Now()
+	}
+
+	return r.clock()
+}
+
 type Key struct {
 	PK        *string `json:",omitempty" dynamodbav:",omitempty"`
 	SK        *string `json:",omitempty" dynamodbav:",omitempty"`
@@ -92,51 +141,161 @@ type Key struct {
 	Condition *string `json:",omitempty" dynamodbav:",omitempty"`
 }
 
-func (r *Ddb) GetItem(key Key) (item map[string]types.AttributeValue, err error) {
-	//log.DebugJson("repository::GetItem", "key", key)
+var ErrItemNotFound = errors.New("item not found")
+
+var ErrConsistentReadOnGsi = errors.New("consistent read is not supported on global secondary indexes")
+
+type GetOption struct {
+	Projection     []string `json:"projection"`
+	ConsistentRead bool     `json:"consistentRead"`
+	// FetchFromBase resolves a GSI hit to its PK/SK and reads the full base table
+	// item. ConsistentRead still fails with ErrConsistentReadOnGsi.
+	FetchFromBase bool `json:"fetchFromBase"`
+	// IncludeDeleted reads soft deleted items as well.
+	IncludeDeleted bool `json:"includeDeleted"`
+}
+
+func mergeGetOption(getOption []GetOption) (option GetOption) {
+	for _, o := range getOption {
+		option.Projection = append(option.Projection, o.Projection...)
+		option.ConsistentRead = option.ConsistentRead || o.ConsistentRead
+		option.FetchFromBase = option.FetchFromBase || o.FetchFromBase
+		option.IncludeDeleted = option.IncludeDeleted || o.IncludeDeleted
+	}
+
+	return
+}
+
+func consistentRead(consistent bool, indexName *string) (consistentRead *bool, err error) {
+	if !consistent {
+		return
+	}
+	if nil != indexName {
+		err = fmt.Errorf("%w (%s)", ErrConsistentReadOnGsi, *indexName)
+		return
+	}
+	consistentRead = aws.Bool(true)
+
+	return
+}
+
+func (r *Ddb) GetItem(key Key, getOption ...GetOption) (item map[string]types.AttributeValue, err error) {
+	r, end := r.begin("GetItem")
+	defer func() { end(err) }()
+
+	var option = mergeGetOption(getOption)
 
 	if nil == key.IndexName {
-		var av map[string]types.AttributeValue
+		var input *dynamodb.GetItemInput
 		var output *dynamodb.GetItemOutput
 
-		av, err = attributevalue.MarshalMap(key)
+		input, err = r.buildGetItemInput(key, option)
 		if err != nil {
 			return
 		}
 
-		input := &dynamodb.GetItemInput{
-			Key:       av,
-			TableName: aws.String(r.tableName),
+		output, err = invoke(r, input, r.dynamoDb.GetItem)
+		if err != nil {
+			return
 		}
 
-		//log.DebugJson("repository::GetItem", "input", input)
+		if nil == output.Item || 0 == len(r.undeleted(r.unexpired([]map[string]types.AttributeValue{output.Item}, option.Projection), option.Projection, option.IncludeDeleted)) {
+			err = fmt.Errorf("%w (%s)", ErrItemNotFound, util.StructToString(key))
+			return
+		}
 
-		output, err = r.dynamoDb.GetItem(context.TODO(), input)
+		item = output.Item
+	} else if option.FetchFromBase {
+		// ConsistentRead fails on the index as it does without FetchFromBase
+		item, err = r.getItemViaGsi(key, GetOption{Projection: baseKeyProjection, ConsistentRead: option.ConsistentRead, IncludeDeleted: option.IncludeDeleted})
 		if err != nil {
 			return
 		}
 
-		if nil == output.Item {
-			err = fmt.Errorf("item not found (%s)", util.StructToString(key))
+		item, err = r.GetItem(baseKeyOf(item), GetOption{Projection: option.Projection, IncludeDeleted: option.IncludeDeleted})
+	} else {
+		item, err = r.getItemViaGsi(key, option)
+	}
+
+	return
+}
+
+func (r *Ddb) buildGetItemInput(key Key, option GetOption) (input *dynamodb.GetItemInput, err error) {
+	var av map[string]types.AttributeValue
+	var expressionAttributeNames = map[string]string{}
+
+	av, err = attributevalue.MarshalMap(key)
+	if err != nil {
+		return
+	}
+
+	input = &dynamodb.GetItemInput{
+		Key:       av,
+		TableName: aws.String(r.tableName),
+	}
+
+	input.ConsistentRead, err = consistentRead(option.ConsistentRead, nil)
+	if err != nil {
+		return
+	}
+
+	input.ProjectionExpression, err = buildProjection(r.deletionProjection(r.expiryProjection(option.Projection), option.IncludeDeleted), expressionAttributeNames)
+	if err != nil {
+		return
+	}
+	if 0 < len(expressionAttributeNames) {
+		input.ExpressionAttributeNames = expressionAttributeNames
+	}
+
+	return
+}
+
+func (r *Ddb) getItemViaGsi(key Key, option GetOption) (item map[string]types.AttributeValue, err error) {
+	var input *dynamodb.QueryInput
+
+	input, err = r.buildGetItemViaGsiInput(key, option)
+	if err != nil {
+		return
+	}
+
+	for {
+		var output *dynamodb.QueryOutput
+
+		output, err = invoke(r, input, r.dynamoDb.Query)
+		if err != nil {
 			return
 		}
 
-		//log.DebugJson("repository::getItemViaGsi", "output", output)
+		if 0 < len(output.Items) {
+			item = output.Items[0]
+			return
+		}
 
-		item = output.Item
-	} else {
-		item, err = r.getItemViaGsi(key)
+		if nil == output.LastEvaluatedKey {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
 	}
 
-	//log.DebugJson("repository::GetItem", "item", item)
+	err = fmt.Errorf("%w (%s)", ErrItemNotFound, util.StructToString(key))
 
 	return
 }
 
-func (r *Ddb) getItemViaGsi(key Key) (item map[string]types.AttributeValue, err error) {
+func (r *Ddb) buildGetItemViaGsiInput(key Key, option GetOption) (input *dynamodb.QueryInput, err error) {
 	var expressionAttributeValues map[string]types.AttributeValue
 	var keyConditionExpression string
 
+	err = validateQueryKey(key)
+	if err != nil {
+		return
+	}
+
+	_, err = consistentRead(option.ConsistentRead, key.IndexName)
+	if err != nil {
+		return
+	}
+
 	expressionAttributeValues = map[string]types.AttributeValue{
 		":gsipk": &types.AttributeValueMemberS{Value: *key.PK},
 	}
@@ -168,7 +327,7 @@ func (r *Ddb) getItemViaGsi(key Key) (item map[string]types.AttributeValue, err
 		}
 	}
 
-	input := &dynamodb.QueryInput{
+	input = &dynamodb.QueryInput{
 		TableName:                 aws.String(r.tableName),
 		IndexName:                 key.IndexName,
 		KeyConditionExpression:    aws.String(keyConditionExpression),
@@ -176,72 +335,123 @@ func (r *Ddb) getItemViaGsi(key Key) (item map[string]types.AttributeValue, err
 		Limit:                     aws.Int32(1),
 	}
 
-	//log.DebugJson("repository::getItemViaGsi", "input", input)
+	expressionAttributeNames := map[string]string{}
+	if filterExpression := r.undeletedFilter(r.unexpiredFilter("", expressionAttributeNames, expressionAttributeValues), option.IncludeDeleted, expressionAttributeNames); "" != filterExpression {
+		input.FilterExpression = aws.String(filterExpression)
+	}
 
-	output, err := r.dynamoDb.Query(context.TODO(), input)
+	input.ProjectionExpression, err = buildProjection(option.Projection, expressionAttributeNames)
 	if err != nil {
 		return
 	}
+	if 0 < len(expressionAttributeNames) {
+		input.ExpressionAttributeNames = expressionAttributeNames
+	}
 
-	//log.DebugJson("repository::getItemViaGsi", "output", output)
+	return
+}
 
-	if 0 == len(output.Items) {
-		err = fmt.Errorf("item not found (%s)", util.StructToString(key))
-		return
+func validateQueryKey(key Key) (err error) {
+	switch {
+	case nil == key.PK:
+		err = fmt.Errorf("invalid key: PK is required (%s)", util.StructToString(key))
+	case nil != key.Condition && nil == key.SK:
+		err = fmt.Errorf("invalid key: SK is required with Condition (%s)", util.StructToString(key))
 	}
 
-	item = output.Items[0]
-
 	return
 }
 
+// DeleteItem deletes the item, or marks it deleted in soft delete mode.
 func (r *Ddb) DeleteItem(key Key) (err error) {
-	//log.DebugJson("repository::DeleteItem", "key", key)
+	r, end := r.begin("DeleteItem")
+	defer func() { end(err) }()
+
+	var input *dynamodb.DeleteItemInput
+
+	if nil != r.softDelete {
+		return r.softDeleteItem(key)
+	}
+
+	input, err = r.buildDeleteItemInput(key)
+	if err != nil {
+		return
+	}
+
+	_, err = invoke(r, input, r.dynamoDb.DeleteItem)
 
+	return
+}
+
+func (r *Ddb) buildDeleteItemInput(key Key) (input *dynamodb.DeleteItemInput, err error) {
 	var av map[string]types.AttributeValue
-	//var output *dynamodb.DeleteItemOutput
 
 	av, err = attributevalue.MarshalMap(key)
 	if err != nil {
 		return
 	}
 
-	input := &dynamodb.DeleteItemInput{
+	input = &dynamodb.DeleteItemInput{
 		Key:       av,
 		TableName: aws.String(r.tableName),
 	}
 
-	//log.DebugJson("repository::DeleteItem", "input", input)
+	return
+}
+
+func (r *Ddb) CreateItem(item interface{}, writeOption ...WriteOption) (err error) {
+	r, end := r.begin("CreateItem")
+	defer func() { end(err) }()
 
-	_, err = r.dynamoDb.DeleteItem(context.TODO(), input)
+	var input *dynamodb.PutItemInput
+
+	input, err = r.buildPutItemInput(item, mergeWriteOption(writeOption))
+	if err != nil {
+		return
+	}
 
-	//log.DebugJson("repository::DeleteItem", "output", output)
+	_, err = invoke(r, input, r.dynamoDb.PutItem)
 
 	return
 }
 
-func (r *Ddb) CreateItem(item interface{}) (err error) {
+func (r *Ddb) buildPutItemInput(item interface{}, option WriteOption) (input *dynamodb.PutItemInput, err error) {
 	avItem, err := attributevalue.MarshalMap(item)
 	if err != nil {
 		return
 	}
 
-	input := &dynamodb.PutItemInput{
+	if 0 < option.TTL {
+		avItem[TTLAttribute] = r.expiresAt(option.TTL)
+	}
+	r.tagEntity(item, avItem)
+
+	input = &dynamodb.PutItemInput{
 		Item:      avItem,
 		TableName: aws.String(r.tableName),
 	}
 
-	//log.DebugJson("repository", "Insert", input)
+	return
+}
+
+func (r *Ddb) UpdateItem(key Key, propertyMap map[string]interface{}, writeOption ...WriteOption) (output *dynamodb.UpdateItemOutput, err error) {
+	r, end := r.begin("UpdateItem")
+	defer func() { end(err) }()
+
+	var input *dynamodb.UpdateItemInput
 
-	_, err = r.dynamoDb.PutItem(context.TODO(), input)
+	// the timestamps are added to a copy, the caller may reuse propertyMap
+	input, err = r.buildUpdateItemInput(key, maps.Clone(propertyMap), mergeWriteOption(writeOption))
 	if err != nil {
 		return
 	}
 
+	output, err = invoke(r, input, r.dynamoDb.UpdateItem)
+
 	return
 }
 
-func (r *Ddb) UpdateItem(key Key, propertyMap map[string]interface{}) (output *dynamodb.UpdateItemOutput, err error) {
+func (r *Ddb) buildUpdateItemInput(key Key, propertyMap map[string]interface{}, option WriteOption) (input *dynamodb.UpdateItemInput, err error) {
 	var keyAv map[string]types.AttributeValue
 	var expressionAv map[string]types.AttributeValue
 	var expressionAttributeNames = map[string]string{}
@@ -255,7 +465,10 @@ func (r *Ddb) UpdateItem(key Key, propertyMap map[string]interface{}) (output *d
 	}
 
 	// add UpdatedTimestamp
-	propertyMap["UpdatedTimestamp"] = time.Now()
+	propertyMap["UpdatedTimestamp"] = r.now()
+	if 0 < option.TTL {
+		propertyMap[TTLAttribute] = r.now().Add(option.TTL).Unix()
+	}
 
 	err = buildExpressionAttributeNamesAndValue(nil, propertyMap, &expressionAttributeNames, &expressionAttributeValues, &expressionNamesAndValues)
 	if nil != err {
@@ -270,9 +483,10 @@ func (r *Ddb) UpdateItem(key Key, propertyMap map[string]interface{}) (output *d
 	for k, v := range expressionNamesAndValues {
 		updateExpressions = append(updateExpressions, fmt.Sprintf("%s=%s", k, v))
 	}
+	// map order is random, sort for a stable expression
+	sort.Strings(updateExpressions)
 
-	//log.DebugJson("", strings.Join(updateExpressions, ", "), nil)
-	input := &dynamodb.UpdateItemInput{
+	input = &dynamodb.UpdateItemInput{
 		Key:                       keyAv,
 		TableName:                 aws.String(r.tableName),
 		ExpressionAttributeNames:  expressionAttributeNames,
@@ -281,8 +495,6 @@ func (r *Ddb) UpdateItem(key Key, propertyMap map[string]interface{}) (output *d
 		ReturnValues:              types.ReturnValueUpdatedNew,
 	}
 
-	output, err = r.dynamoDb.UpdateItem(context.TODO(), input)
-
 	return
 }
 
@@ -390,12 +602,19 @@ func buildFunctionForExpressionAttributeNamesAndValue(parentName *[]string, func
 	return
 }
 
-func (r *Ddb) GetListItem(key Key, arrayOfField string, queryOption QueryOption) (items []map[string]types.AttributeValue, lastEvaluatedKey interface{}, err error) {
-	var output *dynamodb.QueryOutput
+func (r *Ddb) buildQueryInput(key Key, arrayOfField string, queryOption QueryOption) (input *dynamodb.QueryInput, err error) {
 	var expressionAttributeValues map[string]types.AttributeValue
 	var keyConditionExpression string
 	var scanIndexForward = queryOption.ScanIndexForward
 
+	err = validateQueryKey(key)
+	if err != nil {
+		return
+	}
+
+	// the base table keys are PK/SK, index keys are prefixed by the index name
+	indexName := aws.ToString(key.IndexName)
+
 	if nil == scanIndexForward {
 		scanIndexForward = aws.Bool(true)
 	}
@@ -404,7 +623,7 @@ func (r *Ddb) GetListItem(key Key, arrayOfField string, queryOption QueryOption)
 
 	expressionAttributeValues[":gsipk"] = &types.AttributeValueMemberS{Value: *key.PK}
 
-	keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk", *key.IndexName)
+	keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk", indexName)
 
 	if nil != key.SK {
 		if strings.Contains(*key.SK, "/") {
@@ -412,13 +631,13 @@ func (r *Ddb) GetListItem(key Key, arrayOfField string, queryOption QueryOption)
 			expressionAttributeValues[":from"] = &types.AttributeValueMemberS{Value: skRange[0]}
 			expressionAttributeValues[":to"] = &types.AttributeValueMemberS{Value: skRange[1]}
 
-			keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK BETWEEN :from AND :to", *key.IndexName, *key.IndexName)
+			keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK BETWEEN :from AND :to", indexName, indexName)
 		} else {
 			expressionAttributeValues[":gsisk"] = &types.AttributeValueMemberS{Value: *key.SK}
 			if strings.HasSuffix(*key.SK, "#") {
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and begins_with(#%sSK, :gsisk)", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and begins_with(#%sSK, :gsisk)", indexName, indexName)
 			} else {
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK = :gsisk", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK = :gsisk", indexName, indexName)
 			}
 		}
 	}
@@ -431,32 +650,34 @@ func (r *Ddb) GetListItem(key Key, arrayOfField string, queryOption QueryOption)
 
 			switch *key.Condition {
 			case "between":
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK BETWEEN :from AND :to", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK BETWEEN :from AND :to", indexName, indexName)
 			}
 		} else {
 			expressionAttributeValues[":gsisk"] = &types.AttributeValueMemberS{Value: *key.SK}
 			switch *key.Condition {
 			case "equal":
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK = :gsisk", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK = :gsisk", indexName, indexName)
 			case "more_than":
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK > :gsisk", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK > :gsisk", indexName, indexName)
 			case "less_than":
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK < :gsisk", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK < :gsisk", indexName, indexName)
 			case "more_than_equal":
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK >= :gsisk", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK >= :gsisk", indexName, indexName)
 			case "less_than_equal":
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK <= :gsisk", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and #%sSK <= :gsisk", indexName, indexName)
 			case "begins":
-				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and begins_with(#%sSK, :gsisk)", *key.IndexName, *key.IndexName)
+				keyConditionExpression = fmt.Sprintf("#%sPK = :gsipk and begins_with(#%sSK, :gsisk)", indexName, indexName)
 			}
 		}
 	}
 
 	expressionAttributeNames := make(map[string]string)
-	expressionAttributeNames[fmt.Sprintf("#%sPK", *key.IndexName)] = fmt.Sprintf("%sPK", *key.IndexName)
-	expressionAttributeNames[fmt.Sprintf("#%sSK", *key.IndexName)] = fmt.Sprintf("%sSK", *key.IndexName)
+	expressionAttributeNames[fmt.Sprintf("#%sPK", indexName)] = fmt.Sprintf("%sPK", indexName)
+	if nil != key.SK {
+		expressionAttributeNames[fmt.Sprintf("#%sSK", indexName)] = fmt.Sprintf("%sSK", indexName)
+	}
 
-	input := &dynamodb.QueryInput{
+	input = &dynamodb.QueryInput{
 		ExpressionAttributeNames:  expressionAttributeNames,
 		ExpressionAttributeValues: expressionAttributeValues,
 		KeyConditionExpression:    aws.String(keyConditionExpression),
@@ -464,53 +685,211 @@ func (r *Ddb) GetListItem(key Key, arrayOfField string, queryOption QueryOption)
 		ScanIndexForward:          scanIndexForward,
 	}
 
-	if queryOption.Filter != nil {
-		filterExpression, _ := processQueryOptionFilter(queryOption.Filter, expressionAttributeValues, expressionAttributeNames)
+	filterExpression, err := buildFilterExpression(queryOption, expressionAttributeValues, expressionAttributeNames)
+	if err != nil {
+		return
+	}
+	filterExpression = r.undeletedFilter(r.unexpiredFilter(filterExpression, expressionAttributeNames, expressionAttributeValues), queryOption.IncludeDeleted, expressionAttributeNames)
+	if "" != filterExpression {
 		input.FilterExpression = aws.String(filterExpression)
 	}
 
-	if queryOption.Page != nil && queryOption.Page.LastEvaluatedKey != nil {
-		LastEvaluatedKey, err := attributevalue.MarshalMap(queryOption.Page.LastEvaluatedKey)
-		if err != nil {
-			return nil, nil, err
-		}
-		input.ExclusiveStartKey = LastEvaluatedKey
+	input.ExclusiveStartKey, err = buildExclusiveStartKey(queryOption)
+	if err != nil {
+		return
 	}
 
-	if queryOption.Page != nil && 0 < queryOption.Page.PageSize {
-		input.Limit = aws.Int32(int32(queryOption.Page.PageSize))
-	}
+	input.Limit = buildLimit(queryOption)
 
 	if key.IndexName != nil {
 		input.IndexName = key.IndexName
 	}
 
-	if arrayOfField != "" {
-		temp := strings.Split(arrayOfField, ",")
-		tempArrayOfField := make([]string, len(temp))
-		for i, v := range temp {
-			expressionAttributeNames[fmt.Sprintf("#%s", v)] = strings.ReplaceAll(v, "#", "")
-			tempArrayOfField[i] = fmt.Sprintf("#%s", v)
+	input.ConsistentRead, err = consistentRead(queryOption.ConsistentRead, key.IndexName)
+	if err != nil {
+		return
+	}
+
+	input.ProjectionExpression, err = buildProjection(append(splitArrayOfField(arrayOfField), queryOption.Projection...), expressionAttributeNames)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// listQuery is how GetListItem serves a key and query option.
+type listQuery struct {
+	key            Key
+	input          *dynamodb.QueryInput
+	orders         []QueryOptionOrder
+	inMemorySort   bool
+	fetchFromBase  bool
+	baseProjection []string
+}
+
+func (r *Ddb) buildListQuery(key Key, arrayOfField string, queryOption QueryOption) (query listQuery, err error) {
+	query.key = key
+	queryOption, query.inMemorySort, err = r.resolveOrder(key, queryOption)
+	if err != nil {
+		return
+	}
+	query.orders = queryOption.Order
+
+	query.fetchFromBase = queryOption.FetchFromBase && nil != query.key.IndexName
+	if query.fetchFromBase {
+		query.baseProjection = append(splitArrayOfField(arrayOfField), queryOption.Projection...)
+		arrayOfField = ""
+		queryOption.Projection = baseKeyProjection
+	}
+
+	query.input, err = r.buildQueryInput(query.key, arrayOfField, queryOption)
+
+	return
+}
+
+func (r *Ddb) GetListItem(key Key, arrayOfField string, queryOption QueryOption) (items []map[string]types.AttributeValue, lastEvaluatedKey interface{}, err error) {
+	r, end := r.begin("GetListItem")
+	defer func() { end(err) }()
+
+	var output *dynamodb.QueryOutput
+	var query listQuery
+
+	query, err = r.buildListQuery(key, arrayOfField, queryOption)
+	if err != nil {
+		return
+	}
+
+	if query.inMemorySort {
+		items, err = r.queryAndSort(query.input, query.orders)
+		if err == nil && len(items) < 1 {
+			err = fmt.Errorf("%w (%s)", ErrItemNotFound, util.StructToString(query.key))
 		}
-		input.ProjectionExpression = aws.String(strings.Join(tempArrayOfField, ","))
+		if err == nil && query.fetchFromBase {
+			items, err = r.fetchFromBase(items, query.baseProjection, queryOption.IncludeDeleted)
+		}
+		return
 	}
 
-	output, err = r.dynamoDb.Query(context.TODO(), input)
+	output, err = invoke(r, query.input, r.dynamoDb.Query)
 	if err != nil {
 		return
 	}
 	if len(output.Items) < 1 && nil == output.LastEvaluatedKey {
-		err = fmt.Errorf("item not found (%s)", util.StructToString(key))
+		err = fmt.Errorf("%w (%s)", ErrItemNotFound, util.StructToString(query.key))
 		return
 	}
-	LastEvaluatedKey := new(map[string]interface{})
-	if output.LastEvaluatedKey != nil {
-		if err = attributevalue.UnmarshalMap(output.LastEvaluatedKey, &LastEvaluatedKey); err != nil {
-			return
-		}
-		lastEvaluatedKey = LastEvaluatedKey
+	lastEvaluatedKey, err = decodeLastEvaluatedKey(output.LastEvaluatedKey)
+	if err != nil {
+		return
 	}
 	items = output.Items
+	if query.fetchFromBase {
+		items, err = r.fetchFromBase(items, query.baseProjection, queryOption.IncludeDeleted)
+	}
+	return
+}
+
+func (r *Ddb) Scan(indexName *string, arrayOfField string, queryOption QueryOption) (items []map[string]types.AttributeValue, lastEvaluatedKey interface{}, err error) {
+	r, end := r.begin("Scan")
+	defer func() { end(err) }()
+
+	var output *dynamodb.ScanOutput
+	var input *dynamodb.ScanInput
+
+	input, err = r.buildScanInput(indexName, arrayOfField, queryOption)
+	if err != nil {
+		return
+	}
+
+	output, err = invoke(r, input, r.dynamoDb.Scan)
+	if err != nil {
+		return
+	}
+	if len(output.Items) < 1 && nil == output.LastEvaluatedKey {
+		err = fmt.Errorf("%w (%s)", ErrItemNotFound, util.StructToString(queryOption))
+		return
+	}
+	lastEvaluatedKey, err = decodeLastEvaluatedKey(output.LastEvaluatedKey)
+	if err != nil {
+		return
+	}
+	items = output.Items
+	return
+}
+
+func (r *Ddb) buildScanInput(indexName *string, arrayOfField string, queryOption QueryOption) (input *dynamodb.ScanInput, err error) {
+	var expressionAttributeValues = make(map[string]types.AttributeValue)
+	var expressionAttributeNames = make(map[string]string)
+
+	input = &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+		IndexName: indexName,
+	}
+
+	filterExpression, err := buildFilterExpression(queryOption, expressionAttributeValues, expressionAttributeNames)
+	if err != nil {
+		return
+	}
+	filterExpression = r.undeletedFilter(r.unexpiredFilter(filterExpression, expressionAttributeNames, expressionAttributeValues), queryOption.IncludeDeleted, expressionAttributeNames)
+	if "" != filterExpression {
+		input.FilterExpression = aws.String(filterExpression)
+	}
+
+	input.ExclusiveStartKey, err = buildExclusiveStartKey(queryOption)
+	if err != nil {
+		return
+	}
+
+	input.Limit = buildLimit(queryOption)
+
+	input.ConsistentRead, err = consistentRead(queryOption.ConsistentRead, indexName)
+	if err != nil {
+		return
+	}
+
+	input.ProjectionExpression, err = buildProjection(append(splitArrayOfField(arrayOfField), queryOption.Projection...), expressionAttributeNames)
+	if err != nil {
+		return
+	}
+
+	if 0 < len(expressionAttributeNames) {
+		input.ExpressionAttributeNames = expressionAttributeNames
+	}
+	if 0 < len(expressionAttributeValues) {
+		input.ExpressionAttributeValues = expressionAttributeValues
+	}
+
+	return
+}
+
+func buildExclusiveStartKey(queryOption QueryOption) (exclusiveStartKey map[string]types.AttributeValue, err error) {
+	if queryOption.Page != nil && queryOption.Page.LastEvaluatedKey != nil {
+		exclusiveStartKey, err = attributevalue.MarshalMap(queryOption.Page.LastEvaluatedKey)
+	}
+
+	return
+}
+
+func buildLimit(queryOption QueryOption) (limit *int32) {
+	if queryOption.Page != nil && 0 < queryOption.Page.PageSize {
+		limit = aws.Int32(int32(queryOption.Page.PageSize))
+	}
+
+	return
+}
+
+func decodeLastEvaluatedKey(key map[string]types.AttributeValue) (lastEvaluatedKey interface{}, err error) {
+	if nil == key {
+		return
+	}
+
+	LastEvaluatedKey := new(map[string]interface{})
+	if err = attributevalue.UnmarshalMap(key, &LastEvaluatedKey); err != nil {
+		return
+	}
+	lastEvaluatedKey = LastEvaluatedKey
+
 	return
 }
 
@@ -518,99 +897,33 @@ func processQueryOptionFilter(filters map[string]interface{}, expressionAttribut
 	if nil == filters {
 		return
 	}
-	var i = 0
+	var builder = newExpressionBuilder(expressionAttributeName, expressionAttributeValues)
+	var validationError = &FilterValidationError{}
 
-	//parent condition
-	for _, value := range filters {
-		item := value.(map[string]interface{})
-		if item["position"] == nil {
-			filter := getWhere(item)
-			if i == 0 {
-				filterExpression += filter
-			} else {
-				if item["condition"] != nil {
-					filterExpression += " " + item["condition"].(string) + " " + filter
-				} else {
-					filterExpression += " AND " + filter
-				}
-			}
-			expressionAttributeName["#"+item["field"].(string)] = item["field"].(string)
-
-			if item["type"].(string) == "date" {
-				date := strings.Split(item["keyword"].(string), "/")
-				expressionAttributeValues[":"+item["field"].(string)+"Start"] = &types.AttributeValueMemberS{Value: date[0]}
-				expressionAttributeValues[":"+item["field"].(string)+"End"] = &types.AttributeValueMemberS{Value: date[1]}
-			} else if item["type"].(string) != "bool" {
-				expressionAttributeValues[":"+item["field"].(string)] = &types.AttributeValueMemberS{Value: item["keyword"].(string)}
-			} else {
-				expressionAttributeValues[":"+item["field"].(string)] = &types.AttributeValueMemberBOOL{Value: item["keyword"].(bool)}
-			}
-			i++
-		}
+	conditions := parseLegacyFilter(filters, validationError)
+	if 0 < len(validationError.Problems) {
+		err = validationError
+		return
 	}
 
-	//child condition
-	for _, value := range filters {
-		item := value.(map[string]interface{})
-		if item["position"] != nil {
-			if expressionAttributeName["#"+item["field"].(string)] != "" {
-				item["field"] = item["field"].(string) + "_2"
-			}
-			filter := getWhere(item)
-			if i == 0 {
-				filterExpression += filter
-			} else {
-				if item["condition"] != nil {
-					filterExpression += " " + item["condition"].(string) + " " + filter
-				} else {
-					filterExpression += " AND " + filter
-				}
-			}
-			expressionAttributeName["#"+item["field"].(string)] = strings.ReplaceAll(item["field"].(string), "_2", "")
-
-			if item["type"].(string) == "date" {
-				date := strings.Split(item["keyword"].(string), "/")
-				expressionAttributeValues[":"+item["field"].(string)+"Start"] = &types.AttributeValueMemberS{Value: date[0]}
-				expressionAttributeValues[":"+item["field"].(string)+"End"] = &types.AttributeValueMemberS{Value: date[1]}
-			} else if item["type"].(string) != "bool" {
-				expressionAttributeValues[":"+item["field"].(string)] = &types.AttributeValueMemberS{Value: item["keyword"].(string)}
+	for i, condition := range conditions {
+		var filter string
+
+		filter, err = condition.renderCondition(builder)
+		if nil != err {
+			return
+		}
+
+		if i == 0 {
+			filterExpression += filter
+		} else {
+			if "" != condition.condition {
+				filterExpression += " " + condition.condition + " " + filter
 			} else {
-				expressionAttributeValues[":"+item["field"].(string)] = &types.AttributeValueMemberBOOL{Value: item["keyword"].(bool)}
+				filterExpression += " AND " + filter
 			}
-			i++
 		}
 	}
 
 	return
 }
-
-func getWhere(item map[string]interface{}) (filterExpression string) {
-
-	field := item["field"].(string)
-	searchType := item["type"].(string)
-	field = strings.ReplaceAll(field, "\"", "'")
-	switch searchType {
-	case "keyword":
-		filterExpression = fmt.Sprintf("contains (#%s, :%s)", field, field)
-	case "equal":
-		filterExpression = fmt.Sprintf("#%s = :%s", field, field)
-	case "bool":
-		filterExpression = fmt.Sprintf("#%s = :%s", field, field)
-	case "not_equal":
-		filterExpression = fmt.Sprintf("#%s <> :%s", field, field)
-	case "date":
-		filterExpression = fmt.Sprintf("#%s BETWEEN :%s AND :%s", field, field+"Start", field+"End")
-	case "less_than_equal":
-		filterExpression = fmt.Sprintf("#%s <= :%s", field, field)
-	case "less_than":
-		filterExpression = fmt.Sprintf("#%s <= :%s", field, field)
-	case "more_than_equal":
-		filterExpression = fmt.Sprintf("#%s => :%s", field, field)
-	case "more_than":
-		filterExpression = fmt.Sprintf("#%s > :%s", field, field)
-	}
-
-	// log.Debug("getWhere %s %v", query, arg)
-
-	return
-}
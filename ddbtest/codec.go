@@ -0,0 +1,323 @@
+package ddbtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var attributeValueType = reflect.TypeOf((*types.AttributeValue)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+
+// marshal renders an SDK input or output as JSON, attribute values in the
+// DynamoDB JSON format. Nil and empty string fields are left out, as is the
+// ResultMetadata of outputs. Map keys are sorted, so equal values encode equally.
+func marshal(value interface{}) (data json.RawMessage, err error) {
+	encoded, _ := encode(reflect.ValueOf(value))
+
+	return json.Marshal(encoded)
+}
+
+// canonical re-encodes JSON as marshal would.
+func canonical(data json.RawMessage) (json.RawMessage, error) {
+	var buffer bytes.Buffer
+
+	if err := json.Compact(&buffer, data); nil != err {
+		return nil, err
+	}
+
+	generic, err := decodeJSON(buffer.Bytes())
+	if nil != err {
+		return nil, err
+	}
+
+	return json.Marshal(generic)
+}
+
+func decodeJSON(data []byte) (generic interface{}, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&generic)
+
+	return
+}
+
+// unmarshal decodes JSON produced by marshal into target, a pointer to an SDK type.
+func unmarshal(data json.RawMessage, target interface{}) (err error) {
+	var generic interface{}
+
+	if generic, err = decodeJSON(data); nil != err {
+		return
+	}
+
+	return decode(reflect.ValueOf(target).Elem(), generic)
+}
+
+func encode(v reflect.Value) (encoded interface{}, ok bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, false
+		}
+		if attributeValueType == v.Type() {
+			return encodeAttributeValue(v.Interface().(types.AttributeValue)), true
+		}
+		return encode(v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, false
+		}
+		return encode(v.Elem())
+	case reflect.Struct:
+		if timeType == v.Type() {
+			return v.Interface(), true
+		}
+		fields := map[string]interface{}{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || "ResultMetadata" == field.Name {
+				continue
+			}
+			if value, ok := encode(v.Field(i)); ok {
+				fields[field.Name] = value
+			}
+		}
+		return fields, true
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, false
+		}
+		if reflect.Uint8 == v.Type().Elem().Kind() {
+			return v.Bytes(), true
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i], _ = encode(v.Index(i))
+		}
+		return list, true
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+		m := map[string]interface{}{}
+		for _, key := range v.MapKeys() {
+			m[key.String()], _ = encode(v.MapIndex(key))
+		}
+		return m, true
+	case reflect.String:
+		if "" == v.String() {
+			return nil, false
+		}
+		return v.String(), true
+	}
+
+	return v.Interface(), true
+}
+
+func encodeAttributeValue(av types.AttributeValue) interface{} {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]interface{}{"S": v.Value}
+	case *types.AttributeValueMemberN:
+		return map[string]interface{}{"N": v.Value}
+	case *types.AttributeValueMemberB:
+		return map[string]interface{}{"B": v.Value}
+	case *types.AttributeValueMemberSS:
+		return map[string]interface{}{"SS": v.Value}
+	case *types.AttributeValueMemberNS:
+		return map[string]interface{}{"NS": v.Value}
+	case *types.AttributeValueMemberBS:
+		return map[string]interface{}{"BS": v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return map[string]interface{}{"BOOL": v.Value}
+	case *types.AttributeValueMemberNULL:
+		return map[string]interface{}{"NULL": v.Value}
+	case *types.AttributeValueMemberL:
+		list := make([]interface{}, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = encodeAttributeValue(e)
+		}
+		return map[string]interface{}{"L": list}
+	case *types.AttributeValueMemberM:
+		m := map[string]interface{}{}
+		for k, e := range v.Value {
+			m[k] = encodeAttributeValue(e)
+		}
+		return map[string]interface{}{"M": m}
+	}
+
+	return nil
+}
+
+func decodeError(v reflect.Value, data interface{}) error {
+	return fmt.Errorf("cannot decode %T into %s", data, v.Type())
+}
+
+func decode(v reflect.Value, data interface{}) (err error) {
+	if nil == data {
+		return
+	}
+
+	if attributeValueType == v.Type() {
+		var av types.AttributeValue
+
+		if av, err = decodeAttributeValue(data); nil == err {
+			v.Set(reflect.ValueOf(av))
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decode(v.Elem(), data)
+	case reflect.Struct:
+		if timeType == v.Type() {
+			s, ok := data.(string)
+			if !ok {
+				return decodeError(v, data)
+			}
+			return v.Addr().Interface().(*time.Time).UnmarshalText([]byte(s))
+		}
+		fields, ok := data.(map[string]interface{})
+		if !ok {
+			return decodeError(v, data)
+		}
+		for name, value := range fields {
+			field := v.FieldByName(name)
+			if !field.IsValid() {
+				return fmt.Errorf("unknown field %s in %s", name, v.Type())
+			}
+			if err = decode(field, value); nil != err {
+				return
+			}
+		}
+	case reflect.Slice:
+		if reflect.Uint8 == v.Type().Elem().Kind() {
+			var b []byte
+
+			s, ok := data.(string)
+			if !ok {
+				return decodeError(v, data)
+			}
+			if b, err = base64.StdEncoding.DecodeString(s); nil == err {
+				v.SetBytes(b)
+			}
+			return
+		}
+		list, ok := data.([]interface{})
+		if !ok {
+			return decodeError(v, data)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, e := range list {
+			if err = decode(slice.Index(i), e); nil != err {
+				return
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return decodeError(v, data)
+		}
+		target := reflect.MakeMapWithSize(v.Type(), len(m))
+		for key, e := range m {
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err = decode(value, e); nil != err {
+				return
+			}
+			target.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), value)
+		}
+		v.Set(target)
+	case reflect.String:
+		s, ok := data.(string)
+		if !ok {
+			return decodeError(v, data)
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := data.(bool)
+		if !ok {
+			return decodeError(v, data)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+
+		number, ok := data.(json.Number)
+		if !ok {
+			return decodeError(v, data)
+		}
+		if i, err = number.Int64(); nil == err {
+			v.SetInt(i)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+
+		number, ok := data.(json.Number)
+		if !ok {
+			return decodeError(v, data)
+		}
+		if f, err = number.Float64(); nil == err {
+			v.SetFloat(f)
+		}
+	default:
+		return decodeError(v, data)
+	}
+
+	return
+}
+
+func decodeAttributeValue(data interface{}) (av types.AttributeValue, err error) {
+	var typed map[string]interface{}
+	var ok bool
+
+	if typed, ok = data.(map[string]interface{}); !ok || 1 != len(typed) {
+		err = fmt.Errorf("invalid attribute value %v", data)
+		return
+	}
+
+	for t, value := range typed {
+		switch t {
+		case "S":
+			av = &types.AttributeValueMemberS{}
+		case "N":
+			av = &types.AttributeValueMemberN{}
+		case "B":
+			av = &types.AttributeValueMemberB{}
+		case "SS":
+			av = &types.AttributeValueMemberSS{}
+		case "NS":
+			av = &types.AttributeValueMemberNS{}
+		case "BS":
+			av = &types.AttributeValueMemberBS{}
+		case "BOOL":
+			av = &types.AttributeValueMemberBOOL{}
+		case "NULL":
+			av = &types.AttributeValueMemberNULL{}
+		case "L":
+			av = &types.AttributeValueMemberL{}
+		case "M":
+			av = &types.AttributeValueMemberM{}
+		default:
+			err = fmt.Errorf("invalid attribute value type %s", t)
+			return
+		}
+
+		err = decode(reflect.ValueOf(av).Elem().FieldByName("Value"), value)
+	}
+
+	return
+}
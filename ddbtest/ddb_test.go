@@ -0,0 +1,587 @@
+package ddbtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/seill/ddb"
+	"github.com/seill/ddb/ddbtest"
+)
+
+type User struct {
+	ddb.DynamoDbMetaData
+	Name  string
+	Group string
+	Score int
+}
+
+func TestDdb(t *testing.T) {
+	r := ddb.New(ddbtest.NewFakeWithTable("table"), "table")
+
+	for i, name := range []string{"Alice", "Bob", "Carol", "Dave"} {
+		user := User{Name: name, Group: "even", Score: i * 10}
+		user.PK = "USER#" + name
+		user.SK = "PROFILE"
+		user.GSI1PK = aws.String("USER")
+		user.GSI1SK = aws.String(name)
+		if 1 == i%2 {
+			user.Group = "odd"
+		}
+		if err := r.CreateItem(user); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	item, err := r.GetItem(ddb.Key{PK: aws.String("USER#Bob"), SK: aws.String("PROFILE")}, ddb.GetOption{Projection: []string{"Name"}, ConsistentRead: true})
+	if nil != err || 1 != len(item) {
+		t.Fatalf("unexpected item: %v %v", item, err)
+	}
+
+	item, err = r.GetItem(ddb.Key{PK: aws.String("USER"), SK: aws.String("Carol"), IndexName: aws.String(ddb.GSI1)})
+	if nil != err || "USER#Carol" != item["PK"].(*types.AttributeValueMemberS).Value {
+		t.Fatalf("unexpected item: %v %v", item, err)
+	}
+
+	_, err = r.UpdateItem(ddb.Key{PK: aws.String("USER#Bob"), SK: aws.String("PROFILE")}, map[string]interface{}{"Fn:increase:Score": 5, "Group": "even"})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	even := ddb.Equal("Group", "even")
+	where := ddb.And(even, ddb.MoreThan("Score", 0))
+	items, lastEvaluatedKey, err := r.GetListItem(ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)}, "Name,Score", ddb.QueryOption{
+		Where: &where,
+		Order: []ddb.QueryOptionOrder{{Field: "GSI1SK", Direction: "desc"}},
+		Page:  &ddb.QueryOptionPage{PageSize: 3},
+	})
+	if nil != err || 2 != len(items) || nil == lastEvaluatedKey {
+		t.Fatalf("unexpected page: %v %v %v", items, lastEvaluatedKey, err)
+	}
+	var users []User
+	if err = attributevalue.UnmarshalListOfMaps(items, &users); nil != err || "Carol" != users[0].Name || "Bob" != users[1].Name || 15 != users[1].Score {
+		t.Errorf("unexpected users: %+v %v", users, err)
+	}
+
+	count, scannedCount, err := r.Count(ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)}, ddb.QueryOption{Where: &even})
+	if nil != err || 3 != count || 4 != scannedCount {
+		t.Errorf("unexpected count: %d %d %v", count, scannedCount, err)
+	}
+
+	_, err = r.GetUniqueItem(ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)})
+	if !errors.Is(err, ddb.ErrAmbiguousItem) {
+		t.Errorf("expected ErrAmbiguousItem, got %v", err)
+	}
+
+	items, err = r.BatchGetItem([]ddb.Key{
+		{PK: aws.String("USER#Dave"), SK: aws.String("PROFILE")},
+		{PK: aws.String("USER#Nobody"), SK: aws.String("PROFILE")},
+		{PK: aws.String("USER#Alice"), SK: aws.String("PROFILE")},
+	})
+	if nil != err || 2 != len(items) || "USER#Dave" != items[0]["PK"].(*types.AttributeValueMemberS).Value {
+		t.Errorf("unexpected batch: %v %v", items, err)
+	}
+
+	// legacy clients sending numbers as strings hint their type
+	items, _, err = r.GetListItem(ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)}, "Name", ddb.QueryOption{
+		Filter: map[string]interface{}{"score": map[string]interface{}{"field": "Score", "type": "more_than", "keyword": "18", "valueType": "N"}},
+	})
+	if nil != err || "Carol,Dave" != names(items) {
+		t.Errorf("unexpected items: %s %v", names(items), err)
+	}
+
+	odd := ddb.Equal("Group", "odd")
+	items, _, err = r.Scan(nil, "", ddb.QueryOption{Where: &odd})
+	if nil != err || 1 != len(items) {
+		t.Errorf("unexpected scan: %v %v", items, err)
+	}
+
+	if err = r.DeleteItem(ddb.Key{PK: aws.String("USER#Alice"), SK: aws.String("PROFILE")}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = r.GetItem(ddb.Key{PK: aws.String("USER#Alice"), SK: aws.String("PROFILE")}); !errors.Is(err, ddb.ErrItemNotFound) {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+}
+
+func TestTableLifecycle(t *testing.T) {
+	r := ddb.New(ddbtest.NewFake(), "table")
+
+	if err := r.SetupTable(time.Minute); nil != err {
+		t.Fatal(err)
+	}
+	// a second setup finds the table and its time to live in place
+	if err := r.SetupTable(time.Minute); nil != err {
+		t.Fatal(err)
+	}
+
+	description, err := r.DescribeTable()
+	if nil != err || types.TableStatusActive != description.TableStatus || 5 != len(description.GlobalSecondaryIndexes) {
+		t.Fatalf("unexpected table: %v %v", description, err)
+	}
+	if report, err := r.CheckSchema(); nil != err || !report.OK() {
+		t.Errorf("unexpected schema report: %s %v", report, err)
+	}
+	if err = r.EnableTTL("OtherAttribute"); nil == err {
+		t.Error("expected enabling another time to live attribute to fail")
+	}
+
+	if err = r.DeleteTable(time.Minute); nil != err {
+		t.Fatal(err)
+	}
+	var notFound *types.ResourceNotFoundException
+	if _, err = r.DescribeTable(); !errors.As(err, &notFound) {
+		t.Errorf("expected the table to be deleted, got %v", err)
+	}
+
+	if _, err = ddb.New(ddbtest.NewRecorder(ddbtest.NewFake()), "table").CreateTable(); !errors.Is(err, ddb.ErrTableClientRequired) {
+		t.Errorf("expected ErrTableClientRequired, got %v", err)
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fake := ddbtest.NewFakeWithTable("table")
+	r := ddb.New(fake, "table").SetClock(func() time.Time { return now }).SetHideExpired(true)
+
+	for _, name := range []string{"session", "token"} {
+		user := User{Name: name}
+		user.PK = "SESSION"
+		user.SK = name
+		if err := r.CreateItem(user, ddb.WriteOption{TTL: time.Hour}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	properties := map[string]interface{}{"Name": "token"}
+	if _, err := r.UpdateItem(ddb.Key{PK: aws.String("SESSION"), SK: aws.String("token")}, properties, ddb.WriteOption{TTL: 3 * time.Hour}); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(properties) {
+		t.Errorf("UpdateItem changed the caller's map: %v", properties)
+	}
+
+	var user User
+	if err := attributevalue.UnmarshalMap(fake.Items("table")[0], &user); nil != err || nil == user.ExpiresAt || !now.Add(time.Hour).Equal(*user.ExpiresAt) {
+		t.Fatalf("unexpected ExpiresAt: %v %v", user.ExpiresAt, err)
+	}
+
+	// the session expired, DynamoDB has not deleted it yet
+	now = now.Add(2 * time.Hour)
+
+	if _, err := r.GetItem(ddb.Key{PK: aws.String("SESSION"), SK: aws.String("session")}, ddb.GetOption{Projection: []string{"Name"}}); !errors.Is(err, ddb.ErrItemNotFound) {
+		t.Errorf("expected the expired item to be hidden, got %v", err)
+	}
+	item, err := r.GetItem(ddb.Key{PK: aws.String("SESSION"), SK: aws.String("token")}, ddb.GetOption{Projection: []string{"Name"}})
+	if nil != err || 1 != len(item) {
+		t.Errorf("unexpected item: %v %v", item, err)
+	}
+	items, _, err := r.GetListItem(ddb.Key{PK: aws.String("SESSION")}, "Name", ddb.QueryOption{})
+	if nil != err || 1 != len(items) {
+		t.Errorf("unexpected items: %v %v", items, err)
+	}
+	items, err = r.BatchGetItem([]ddb.Key{{PK: aws.String("SESSION"), SK: aws.String("session")}, {PK: aws.String("SESSION"), SK: aws.String("token")}})
+	if nil != err || 1 != len(items) {
+		t.Errorf("unexpected items: %v %v", items, err)
+	}
+	if count, _, err := r.Count(ddb.Key{PK: aws.String("SESSION")}, ddb.QueryOption{}); nil != err || 1 != count {
+		t.Errorf("unexpected count: %d %v", count, err)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	fake := ddbtest.NewFakeWithTable("table")
+	r := ddb.New(fake, "table").SetSoftDelete(ddb.SoftDeletePolicy{MoveIndexKeys: true})
+	key := ddb.Key{PK: aws.String("USER#Alice"), SK: aws.String("PROFILE")}
+	index := ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)}
+
+	for _, name := range []string{"Alice", "Bob"} {
+		user := User{Name: name}
+		user.PK = "USER#" + name
+		user.SK = "PROFILE"
+		user.GSI1PK = aws.String("USER")
+		user.GSI1SK = aws.String(name)
+		if err := r.CreateItem(user); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.DeleteItem(key); nil != err {
+		t.Fatal(err)
+	}
+	// deleting again, or a missing item, is not an error
+	if err := r.DeleteItem(key); nil != err {
+		t.Fatal(err)
+	}
+	if err := r.DeleteItem(ddb.Key{PK: aws.String("USER#Nobody"), SK: aws.String("PROFILE")}); nil != err {
+		t.Fatal(err)
+	}
+
+	if _, err := r.GetItem(key); !errors.Is(err, ddb.ErrItemNotFound) {
+		t.Errorf("expected the deleted item to be hidden, got %v", err)
+	}
+	var user User
+	item, err := r.GetItem(key, ddb.GetOption{IncludeDeleted: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err = attributevalue.UnmarshalMap(item, &user); nil != err || nil == user.DeletedTimestamp || nil != user.GSI1PK || nil == item["DeletedGSI1PK"] {
+		t.Errorf("unexpected deleted item: %v %v", item, err)
+	}
+	items, _, err := r.GetListItem(index, "Name", ddb.QueryOption{IncludeDeleted: true})
+	if nil != err || 1 != len(items) {
+		t.Errorf("expected the deleted item out of the index, got %v %v", items, err)
+	}
+
+	if err = r.Restore(key); nil != err {
+		t.Fatal(err)
+	}
+	if err = r.Restore(key); !errors.Is(err, ddb.ErrNotDeleted) {
+		t.Errorf("expected ErrNotDeleted, got %v", err)
+	}
+	items, _, err = r.GetListItem(index, "Name", ddb.QueryOption{})
+	if nil != err || 2 != len(items) {
+		t.Errorf("expected the restored item back in the index, got %v %v", items, err)
+	}
+
+	if err = r.Purge(key); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = r.GetItem(key, ddb.GetOption{IncludeDeleted: true}); !errors.Is(err, ddb.ErrItemNotFound) {
+		t.Errorf("expected the purged item to be gone, got %v", err)
+	}
+
+	// without moving index keys, queries filter deleted items out
+	r = ddb.New(fake, "table").SetSoftDelete(ddb.SoftDeletePolicy{})
+	if err = r.DeleteItem(ddb.Key{PK: aws.String("USER#Bob"), SK: aws.String("PROFILE")}); nil != err {
+		t.Fatal(err)
+	}
+	if _, _, err = r.GetListItem(index, "Name", ddb.QueryOption{}); !errors.Is(err, ddb.ErrItemNotFound) {
+		t.Errorf("expected the deleted item to be filtered out, got %v", err)
+	}
+	if count, _, err := r.Count(index, ddb.QueryOption{IncludeDeleted: true}); nil != err || 1 != count {
+		t.Errorf("unexpected count: %d %v", count, err)
+	}
+}
+
+type Order struct {
+	ddb.DynamoDbMetaData
+	Amount int
+}
+
+func TestEntityRegistry(t *testing.T) {
+	registry := ddb.NewEntityRegistry()
+	if err := registry.Register("User", User{}); nil != err {
+		t.Fatal(err)
+	}
+	if err := registry.Register("Order", &Order{}); nil != err {
+		t.Fatal(err)
+	}
+	fake := ddbtest.NewFakeWithTable("table")
+	r := ddb.New(fake, "table").SetEntityRegistry(registry)
+
+	user := User{Name: "Alice"}
+	user.PK = "USER#Alice"
+	user.SK = "PROFILE"
+	order := Order{Amount: 42}
+	order.PK = "USER#Alice"
+	order.SK = "ORDER#1"
+	for _, item := range []interface{}{user, &order} {
+		if err := r.CreateItem(item); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	records, _, err := r.GetListEntity(ddb.Key{PK: aws.String("USER#Alice")}, ddb.QueryOption{})
+	if nil != err || 2 != len(records) {
+		t.Fatalf("unexpected records: %v %v", records, err)
+	}
+	if decoded, ok := records[0].(*Order); !ok || 42 != decoded.Amount {
+		t.Errorf("unexpected order: %#v", records[0])
+	}
+	if decoded, ok := records[1].(*User); !ok || "Alice" != decoded.Name {
+		t.Errorf("unexpected user: %#v", records[1])
+	}
+
+	record, err := r.GetEntity(ddb.Key{PK: aws.String("USER#Alice"), SK: aws.String("PROFILE")}, ddb.GetOption{Projection: []string{"Name"}})
+	if decoded, ok := record.(*User); nil != err || !ok || "Alice" != decoded.Name {
+		t.Errorf("unexpected user: %#v %v", record, err)
+	}
+
+	// items written without the registry have no discriminator
+	untagged := User{Name: "Bob"}
+	untagged.PK = "USER#Bob"
+	untagged.SK = "PROFILE"
+	if err = ddb.New(fake, "table").CreateItem(untagged); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = r.GetEntity(ddb.Key{PK: aws.String("USER#Bob"), SK: aws.String("PROFILE")}); !errors.Is(err, ddb.ErrUnknownEntityType) {
+		t.Errorf("expected ErrUnknownEntityType, got %v", err)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	r := ddb.New(ddbtest.NewFakeWithTable("table"), "table").SetIndexOrderField(ddb.GSI2, "Name")
+	key := ddb.Key{PK: aws.String("USER#Alice")}
+
+	for i, name := range []string{"Carol", "Alice", "Bob"} {
+		user := User{Name: name, Score: i}
+		user.PK = "USER#Alice"
+		user.SK = fmt.Sprintf("FRIEND#%d", i)
+		user.GSI2PK = aws.String("FRIEND")
+		user.GSI2SK = aws.String(name)
+		if err := r.CreateItem(user); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	// GSI2 is sorted by Name, but the key reads the base table
+	if _, _, err := r.GetListItem(key, "Name", ddb.QueryOption{Order: []ddb.QueryOptionOrder{{Field: "Name"}}}); !errors.Is(err, ddb.ErrOrderNotSupported) {
+		t.Errorf("expected ErrOrderNotSupported, got %v", err)
+	}
+
+	items, _, err := r.GetListItem(key, "Name", ddb.QueryOption{Order: []ddb.QueryOptionOrder{{Field: "Name", Direction: "desc"}}, InMemorySort: true})
+	if nil != err || "Carol,Bob,Alice" != names(items) {
+		t.Errorf("unexpected items: %s %v", names(items), err)
+	}
+
+	// Score is read to sort on, but not returned
+	items, _, err = r.GetListItem(key, "Name", ddb.QueryOption{Order: []ddb.QueryOptionOrder{{Field: "Score", Direction: "desc"}}, InMemorySort: true})
+	if nil != err || "Bob,Alice,Carol" != names(items) || nil != items[0]["Score"] || 1 != len(items[0]) {
+		t.Errorf("unexpected items: %v %v", items, err)
+	}
+
+	// the whole partition is read before the first page is cut
+	even := ddb.In("Score", 0, 2)
+	items, lastEvaluatedKey, err := r.GetListItem(key, "Name", ddb.QueryOption{
+		Where:        &even,
+		Order:        []ddb.QueryOptionOrder{{Field: "Name"}},
+		InMemorySort: true,
+		Page:         &ddb.QueryOptionPage{PageSize: 1},
+	})
+	if nil != err || "Bob" != names(items) || nil != lastEvaluatedKey {
+		t.Errorf("unexpected page: %s %v %v", names(items), lastEvaluatedKey, err)
+	}
+
+	_, _, err = r.GetListItem(key, "Name", ddb.QueryOption{
+		Order:        []ddb.QueryOptionOrder{{Field: "Name"}},
+		InMemorySort: true,
+		Page:         &ddb.QueryOptionPage{PageSize: 1, LastEvaluatedKey: map[string]interface{}{"PK": "USER#Alice", "SK": "FRIEND#0"}},
+	})
+	if !errors.Is(err, ddb.ErrOrderNotSupported) {
+		t.Errorf("expected ErrOrderNotSupported, got %v", err)
+	}
+
+	items, _, err = r.GetListItem(ddb.Key{PK: aws.String("FRIEND"), IndexName: aws.String(ddb.GSI2)}, "Name", ddb.QueryOption{Order: []ddb.QueryOptionOrder{{Field: "Name", Direction: "desc"}}})
+	if nil != err || "Carol,Bob,Alice" != names(items) {
+		t.Errorf("unexpected items: %s %v", names(items), err)
+	}
+}
+
+func TestCount(t *testing.T) {
+	var queries int
+
+	r := ddb.New(ddbtest.NewFakeWithTable("table"), "table").Use(ddb.MiddlewareFunc(func(ctx context.Context, operation *ddb.Operation) {
+		queries++
+	}))
+	key := ddb.Key{PK: aws.String("GROUP#big")}
+
+	// 300KB items, so that a 1MB page holds four of them
+	for i := 0; i < 10; i++ {
+		user := User{Name: strings.Repeat("x", 300*1024), Score: i}
+		user.PK = "GROUP#big"
+		user.SK = fmt.Sprintf("USER#%02d", i)
+		if err := r.CreateItem(user); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	queries = 0
+	count, scannedCount, err := r.Count(key, ddb.QueryOption{})
+	if nil != err || 10 != count || 10 != scannedCount || 3 != queries {
+		t.Errorf("unexpected count: %d %d in %d queries %v", count, scannedCount, queries, err)
+	}
+
+	queries = 0
+	even := ddb.In("Score", 0, 2, 4, 6, 8)
+	count, scannedCount, err = r.Count(key, ddb.QueryOption{Where: &even})
+	if nil != err || 5 != count || 10 != scannedCount || 3 != queries {
+		t.Errorf("unexpected count: %d %d in %d queries %v", count, scannedCount, queries, err)
+	}
+}
+
+func TestUniqueItem(t *testing.T) {
+	var ambiguous *ddb.AmbiguousItemError
+
+	r := ddb.New(ddbtest.NewFakeWithTable("table"), "table").SetSoftDelete(ddb.SoftDeletePolicy{})
+	email := func(address string) ddb.Key {
+		return ddb.Key{PK: aws.String("EMAIL#" + address), IndexName: aws.String(ddb.GSI1)}
+	}
+
+	for i, name := range []string{"Alice", "Bob", "Carol", "Dave", "Erin", "Frank"} {
+		user := User{Name: name, Score: i}
+		user.PK = "USER#" + name
+		user.SK = "PROFILE"
+		user.GSI1PK = aws.String("EMAIL#shared@example.com")
+		user.GSI1SK = aws.String(name)
+		if "Erin" == name || "Frank" == name {
+			user.GSI1PK = aws.String("EMAIL#team@example.com")
+		}
+		if err := r.CreateItem(user); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := r.GetUniqueItem(email("shared@example.com"))
+	if !errors.As(err, &ambiguous) || 2 != len(ambiguous.Items) || !errors.Is(err, ddb.ErrAmbiguousItem) {
+		t.Errorf("expected *AmbiguousItemError, got %v", err)
+	}
+
+	// pages of two read the deleted Alice, Bob and Carol before Dave
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		if err = r.DeleteItem(ddb.Key{PK: aws.String("USER#" + name), SK: aws.String("PROFILE")}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	item, err := r.GetUniqueItem(email("shared@example.com"), ddb.GetOption{Projection: []string{"Name"}})
+	if nil != err || "Dave" != names([]map[string]types.AttributeValue{item}) {
+		t.Errorf("unexpected item: %v %v", item, err)
+	}
+
+	for direction, expected := range map[string]string{"asc": "Erin", "desc": "Frank"} {
+		item, err = r.GetFirstItem(email("team@example.com"), ddb.QueryOption{Order: []ddb.QueryOptionOrder{{Field: "GSI1SK", Direction: direction}}})
+		if nil != err || expected != names([]map[string]types.AttributeValue{item}) {
+			t.Errorf("unexpected %s item: %v %v", direction, item, err)
+		}
+	}
+}
+
+func TestFirstItemAfterFilteredPages(t *testing.T) {
+	r := ddb.New(ddbtest.NewFakeWithTable("table"), "table")
+	key := ddb.Key{PK: aws.String("GROUP#big")}
+
+	// 300KB items, so that a 1MB page holds four of them
+	for i := 0; i < 10; i++ {
+		user := User{Name: fmt.Sprintf("User%02d", i), Group: strings.Repeat("x", 300*1024), Score: i}
+		user.PK = "GROUP#big"
+		user.SK = fmt.Sprintf("USER#%02d", i)
+		if err := r.CreateItem(user); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	// the only match sits on the last of three pages in the direction read
+	cases := []struct {
+		direction string
+		where     ddb.FilterExpression
+		expected  string
+	}{
+		{"asc", ddb.MoreThan("Score", 8), "User09"},
+		{"desc", ddb.LessThan("Score", 1), "User00"},
+	}
+
+	for _, c := range cases {
+		item, err := r.GetFirstItem(key, ddb.QueryOption{Where: &c.where, Order: []ddb.QueryOptionOrder{{Field: "SK", Direction: c.direction}}})
+		if nil != err || c.expected != names([]map[string]types.AttributeValue{item}) {
+			t.Errorf("unexpected %s item: %s %v", c.direction, names([]map[string]types.AttributeValue{item}), err)
+		}
+	}
+}
+
+// staleIndexClient deletes the base item of Bob once a query has found it, as
+// if the index lagged behind the table.
+type staleIndexClient struct {
+	ddb.Client
+}
+
+func (c staleIndexClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.QueryOutput, err error) {
+	if output, err = c.Client.Query(ctx, params, optFns...); nil != err {
+		return
+	}
+
+	_, err = c.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: params.TableName,
+		Key:       map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "USER#Bob"}, "SK": &types.AttributeValueMemberS{Value: "PROFILE"}},
+	})
+
+	return
+}
+
+func TestFetchFromBase(t *testing.T) {
+	fake := ddbtest.NewFakeWithTable("table")
+	r := ddb.New(fake, "table")
+	index := ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)}
+
+	for i, name := range []string{"Alice", "Bob", "Carol"} {
+		user := User{Name: name, Group: "staff", Score: i}
+		user.PK = "USER#" + name
+		user.SK = "PROFILE"
+		user.GSI1PK = aws.String("USER")
+		user.GSI1SK = aws.String(name)
+		if err := r.CreateItem(user); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	item, err := r.GetItem(ddb.Key{PK: aws.String("USER"), SK: aws.String("Carol"), IndexName: aws.String(ddb.GSI1)}, ddb.GetOption{Projection: []string{"Name"}, FetchFromBase: true})
+	if nil != err || 1 != len(item) || "Carol" != names([]map[string]types.AttributeValue{item}) {
+		t.Errorf("unexpected item: %v %v", item, err)
+	}
+
+	items, _, err := r.GetListItem(index, "Name", ddb.QueryOption{FetchFromBase: true, Order: []ddb.QueryOptionOrder{{Field: "GSI1SK", Direction: "desc"}}})
+	if nil != err || "Carol,Bob,Alice" != names(items) {
+		t.Fatalf("unexpected items: %s %v", names(items), err)
+	}
+	for _, item := range items {
+		if 1 != len(item) {
+			t.Errorf("expected only Name, got %v", item)
+		}
+	}
+
+	// the whole base item without a projection
+	items, _, err = r.GetListItem(index, "", ddb.QueryOption{FetchFromBase: true})
+	if nil != err || 3 != len(items) || nil == items[0]["PK"] || nil == items[0]["Group"] {
+		t.Errorf("unexpected items: %v %v", items, err)
+	}
+
+	// ConsistentRead fails on an index whether or not the base item is fetched
+	carol := ddb.Key{PK: aws.String("USER"), SK: aws.String("Carol"), IndexName: aws.String(ddb.GSI1)}
+	consistent := ddb.GetOption{ConsistentRead: true, FetchFromBase: true}
+	if _, err = r.GetItem(carol, consistent); !errors.Is(err, ddb.ErrConsistentReadOnGsi) {
+		t.Errorf("expected GetItem to fail with ErrConsistentReadOnGsi, got %v", err)
+	}
+	if _, err = r.ExplainGetItem(carol, consistent); !errors.Is(err, ddb.ErrConsistentReadOnGsi) {
+		t.Errorf("expected ExplainGetItem to fail with ErrConsistentReadOnGsi, got %v", err)
+	}
+	if _, err = r.GetUniqueItem(carol, consistent); !errors.Is(err, ddb.ErrConsistentReadOnGsi) {
+		t.Errorf("expected GetUniqueItem to fail with ErrConsistentReadOnGsi, got %v", err)
+	}
+	if _, _, err = r.GetListItem(index, "", ddb.QueryOption{ConsistentRead: true, FetchFromBase: true}); !errors.Is(err, ddb.ErrConsistentReadOnGsi) {
+		t.Errorf("expected GetListItem to fail with ErrConsistentReadOnGsi, got %v", err)
+	}
+
+	// index hits whose base item is gone are skipped
+	stale := ddb.New(staleIndexClient{Client: fake}, "table")
+	items, _, err = stale.GetListItem(index, "Name,Score", ddb.QueryOption{FetchFromBase: true})
+	if nil != err || "Alice,Carol" != names(items) || 2 != len(items[1]) {
+		t.Errorf("unexpected items: %s %v %v", names(items), items, err)
+	}
+}
+
+func names(items []map[string]types.AttributeValue) string {
+	var values []string
+
+	for _, item := range items {
+		if name, ok := item["Name"].(*types.AttributeValueMemberS); ok {
+			values = append(values, name.Value)
+		}
+	}
+
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,1337 @@
+package ddbtest
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// expressionContext resolves the placeholders of one request and tracks which
+// of them are used, as DynamoDB rejects unused names and values.
+type expressionContext struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newExpressionContext(names map[string]string, values map[string]types.AttributeValue) *expressionContext {
+	return &expressionContext{
+		names:      names,
+		values:     values,
+		usedNames:  map[string]bool{},
+		usedValues: map[string]bool{},
+	}
+}
+
+func (c *expressionContext) name(placeholder string) (name string, err error) {
+	var ok bool
+
+	if name, ok = c.names[placeholder]; !ok {
+		err = validationError("An expression attribute name used in the document path is not defined; attribute name: %s", placeholder)
+		return
+	}
+	c.usedNames[placeholder] = true
+
+	return
+}
+
+func (c *expressionContext) value(placeholder string) (value types.AttributeValue, err error) {
+	var ok bool
+
+	if value, ok = c.values[placeholder]; !ok || nil == value {
+		err = validationError("An expression attribute value used in expression is not defined; attribute value: %s", placeholder)
+		return
+	}
+	c.usedValues[placeholder] = true
+
+	return
+}
+
+func (c *expressionContext) checkUnused() (err error) {
+	for _, placeholder := range sortedKeys(c.names) {
+		if !c.usedNames[placeholder] {
+			return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", placeholder)
+		}
+	}
+	for _, placeholder := range sortedKeys(c.values) {
+		if !c.usedValues[placeholder] {
+			return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", placeholder)
+		}
+	}
+
+	return
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenName
+	tokenValue
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || '_' == r
+}
+
+func tokenize(expression string) (tokens []token, err error) {
+	var runes = []rune(expression)
+
+	for i := 0; i < len(runes); {
+		var r = runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case '#' == r || ':' == r:
+			j := i + 1
+			for j < len(runes) && isIdentRune(runes[j]) {
+				j++
+			}
+			if j == i+1 {
+				err = validationError("Invalid expression: unexpected %q in %s", string(r), expression)
+				return
+			}
+			kind := tokenName
+			if ':' == r {
+				kind = tokenValue
+			}
+			tokens = append(tokens, token{kind: kind, text: string(runes[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[i:j])})
+			i = j
+		case isIdentRune(r):
+			j := i
+			for j < len(runes) && isIdentRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[i:j])})
+			i = j
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				switch string(runes[i : i+2]) {
+				case "<>", "<=", ">=":
+					text = string(runes[i : i+2])
+				}
+			}
+			if !strings.Contains("()[],.=<>+-", string(r)) {
+				err = validationError("Invalid expression: unexpected %q in %s", text, expression)
+				return
+			}
+			tokens = append(tokens, token{kind: tokenPunct, text: text})
+			i += len([]rune(text))
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF})
+
+	return
+}
+
+type parser struct {
+	expression string
+	tokens     []token
+	pos        int
+	ctx        *expressionContext
+}
+
+func newParser(expression string, ctx *expressionContext) (p *parser, err error) {
+	var tokens []token
+
+	tokens, err = tokenize(expression)
+	if nil != err {
+		return
+	}
+
+	p = &parser{expression: expression, tokens: tokens, ctx: ctx}
+
+	return
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+
+	return token{kind: tokenEOF}
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if tokenEOF != t.kind {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return tokenPunct == t.kind && text == t.text
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return tokenIdent == t.kind && strings.EqualFold(keyword, t.text)
+}
+
+func (p *parser) expect(text string) (err error) {
+	if !p.isPunct(text) {
+		err = p.syntaxError()
+		return
+	}
+	p.next()
+
+	return
+}
+
+func (p *parser) syntaxError() error {
+	t := p.peek()
+	if tokenEOF == t.kind {
+		return validationError("Invalid expression: unexpected end of expression: %s", p.expression)
+	}
+
+	return validationError("Invalid expression: syntax error; token: %q, expression: %s", t.text, p.expression)
+}
+
+func (p *parser) done() (err error) {
+	if tokenEOF != p.peek().kind {
+		err = p.syntaxError()
+	}
+
+	return
+}
+
+type pathElement struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+type documentPath []pathElement
+
+func (d documentPath) String() string {
+	var b strings.Builder
+
+	for i, element := range d {
+		if element.isIndex {
+			fmt.Fprintf(&b, "[%d]", element.index)
+			continue
+		}
+		if 0 < i {
+			b.WriteString(".")
+		}
+		b.WriteString(element.name)
+	}
+
+	return b.String()
+}
+
+func (d documentPath) get(it item) (value types.AttributeValue) {
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: it}
+
+	for _, element := range d {
+		if element.isIndex {
+			list, ok := current.(*types.AttributeValueMemberL)
+			if !ok || element.index >= len(list.Value) {
+				return nil
+			}
+			current = list.Value[element.index]
+			continue
+		}
+
+		m, ok := current.(*types.AttributeValueMemberM)
+		if !ok {
+			return nil
+		}
+		if current, ok = m.Value[element.name]; !ok {
+			return nil
+		}
+	}
+
+	return current
+}
+
+// container returns the map or list holding the last element of d.
+func (d documentPath) container(it item) (container types.AttributeValue, err error) {
+	container = &types.AttributeValueMemberM{Value: it}
+
+	if 1 < len(d) {
+		container = d[:len(d)-1].get(it)
+	}
+
+	last := d[len(d)-1]
+	switch container.(type) {
+	case *types.AttributeValueMemberM:
+		if !last.isIndex {
+			return
+		}
+	case *types.AttributeValueMemberL:
+		if last.isIndex {
+			return
+		}
+	}
+
+	err = validationError("The document path provided in the update expression is invalid for update")
+
+	return
+}
+
+func (d documentPath) set(it item, value types.AttributeValue) (err error) {
+	var container types.AttributeValue
+	var last = d[len(d)-1]
+
+	container, err = d.container(it)
+	if nil != err {
+		return
+	}
+
+	switch c := container.(type) {
+	case *types.AttributeValueMemberM:
+		c.Value[last.name] = value
+	case *types.AttributeValueMemberL:
+		if last.index < len(c.Value) {
+			c.Value[last.index] = value
+		} else {
+			c.Value = append(c.Value, value)
+		}
+	}
+
+	return
+}
+
+func (d documentPath) remove(it item) {
+	var last = d[len(d)-1]
+
+	container, err := d.container(it)
+	if nil != err {
+		return
+	}
+
+	switch c := container.(type) {
+	case *types.AttributeValueMemberM:
+		delete(c.Value, last.name)
+	case *types.AttributeValueMemberL:
+		if last.index < len(c.Value) {
+			c.Value = append(c.Value[:last.index], c.Value[last.index+1:]...)
+		}
+	}
+}
+
+func (p *parser) parsePathElementName() (name string, err error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenName:
+		name, err = p.ctx.name(t.text)
+	case tokenIdent:
+		name = t.text
+	default:
+		p.pos--
+		err = p.syntaxError()
+	}
+
+	return
+}
+
+func (p *parser) parsePath() (path documentPath, err error) {
+	var name string
+
+	name, err = p.parsePathElementName()
+	if nil != err {
+		return
+	}
+	path = documentPath{{name: name}}
+
+	for {
+		switch {
+		case p.isPunct("."):
+			p.next()
+			name, err = p.parsePathElementName()
+			if nil != err {
+				return
+			}
+			path = append(path, pathElement{name: name})
+		case p.isPunct("["):
+			p.next()
+			t := p.next()
+			if tokenNumber != t.kind {
+				p.pos--
+				err = p.syntaxError()
+				return
+			}
+			index, _ := strconv.Atoi(t.text)
+			if err = p.expect("]"); nil != err {
+				return
+			}
+			path = append(path, pathElement{index: index, isIndex: true})
+		default:
+			return
+		}
+	}
+}
+
+type operand interface {
+	eval(it item) (types.AttributeValue, error)
+}
+
+type pathOperand struct {
+	path documentPath
+}
+
+func (o pathOperand) eval(it item) (types.AttributeValue, error) {
+	return o.path.get(it), nil
+}
+
+type valueOperand struct {
+	value types.AttributeValue
+}
+
+func (o valueOperand) eval(it item) (types.AttributeValue, error) {
+	return o.value, nil
+}
+
+type sizeOperand struct {
+	path documentPath
+}
+
+func (o sizeOperand) eval(it item) (value types.AttributeValue, err error) {
+	if n, ok := size(o.path.get(it)); ok {
+		value = &types.AttributeValueMemberN{Value: strconv.Itoa(n)}
+	}
+
+	return
+}
+
+func (p *parser) parseOperand() (o operand, err error) {
+	t := p.peek()
+
+	switch {
+	case tokenValue == t.kind:
+		var value types.AttributeValue
+
+		p.next()
+		value, err = p.ctx.value(t.text)
+		o = valueOperand{value: value}
+	case tokenIdent == t.kind && "size" == t.text && p.peekAt(1).kind == tokenPunct && "(" == p.peekAt(1).text:
+		var path documentPath
+
+		p.next()
+		p.next()
+		if path, err = p.parsePath(); nil != err {
+			return
+		}
+		err = p.expect(")")
+		o = sizeOperand{path: path}
+	default:
+		var path documentPath
+
+		path, err = p.parsePath()
+		o = pathOperand{path: path}
+	}
+
+	return
+}
+
+type condition interface {
+	eval(it item) (bool, error)
+}
+
+type andCondition struct {
+	left, right condition
+}
+
+func (c andCondition) eval(it item) (result bool, err error) {
+	if result, err = c.left.eval(it); nil != err || !result {
+		return
+	}
+
+	return c.right.eval(it)
+}
+
+type orCondition struct {
+	left, right condition
+}
+
+func (c orCondition) eval(it item) (result bool, err error) {
+	if result, err = c.left.eval(it); nil != err || result {
+		return
+	}
+
+	return c.right.eval(it)
+}
+
+type notCondition struct {
+	condition condition
+}
+
+func (c notCondition) eval(it item) (result bool, err error) {
+	result, err = c.condition.eval(it)
+
+	return !result, err
+}
+
+type compareCondition struct {
+	comparator  string
+	left, right operand
+}
+
+func (c compareCondition) eval(it item) (result bool, err error) {
+	var left, right types.AttributeValue
+
+	if left, err = c.left.eval(it); nil != err {
+		return
+	}
+	if right, err = c.right.eval(it); nil != err {
+		return
+	}
+	if nil == left || nil == right {
+		return
+	}
+
+	switch c.comparator {
+	case "=":
+		return equal(left, right), nil
+	case "<>":
+		return !equal(left, right), nil
+	}
+
+	cmp, ok := compareScalar(left, right)
+	if !ok {
+		return
+	}
+
+	switch c.comparator {
+	case "<":
+		result = cmp < 0
+	case "<=":
+		result = cmp <= 0
+	case ">":
+		result = cmp > 0
+	case ">=":
+		result = cmp >= 0
+	}
+
+	return
+}
+
+type betweenCondition struct {
+	value, low, high operand
+}
+
+func (c betweenCondition) eval(it item) (result bool, err error) {
+	var value, low, high types.AttributeValue
+
+	if value, err = c.value.eval(it); nil != err {
+		return
+	}
+	if low, err = c.low.eval(it); nil != err {
+		return
+	}
+	if high, err = c.high.eval(it); nil != err {
+		return
+	}
+
+	if cmp, ok := compareScalar(low, high); ok && cmp > 0 {
+		err = validationError("Invalid KeyConditionExpression: The BETWEEN operator requires upper bound to be greater than or equal to lower bound")
+		return
+	}
+
+	lowCmp, okLow := compareScalar(value, low)
+	highCmp, okHigh := compareScalar(value, high)
+	result = okLow && okHigh && lowCmp >= 0 && highCmp <= 0
+
+	return
+}
+
+type inCondition struct {
+	value  operand
+	values []operand
+}
+
+func (c inCondition) eval(it item) (result bool, err error) {
+	var value types.AttributeValue
+
+	if value, err = c.value.eval(it); nil != err || nil == value {
+		return
+	}
+
+	for _, candidate := range c.values {
+		var v types.AttributeValue
+
+		if v, err = candidate.eval(it); nil != err {
+			return
+		}
+		if equal(value, v) {
+			return true, nil
+		}
+	}
+
+	return
+}
+
+type functionCondition struct {
+	function string
+	path     documentPath
+	argument operand
+}
+
+func (c functionCondition) eval(it item) (result bool, err error) {
+	var value = c.path.get(it)
+	var argument types.AttributeValue
+
+	if nil != c.argument {
+		if argument, err = c.argument.eval(it); nil != err {
+			return
+		}
+	}
+
+	switch c.function {
+	case "attribute_exists":
+		result = nil != value
+	case "attribute_not_exists":
+		result = nil == value
+	case "attribute_type":
+		t, ok := argument.(*types.AttributeValueMemberS)
+		if !ok {
+			err = validationError("Invalid ConditionExpression: attribute_type requires a string type operand")
+			return
+		}
+		result = nil != value && typeOf(value) == t.Value
+	case "begins_with":
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			if prefix, ok := argument.(*types.AttributeValueMemberS); ok {
+				result = strings.HasPrefix(v.Value, prefix.Value)
+			}
+		case *types.AttributeValueMemberB:
+			if prefix, ok := argument.(*types.AttributeValueMemberB); ok {
+				result = strings.HasPrefix(string(v.Value), string(prefix.Value))
+			}
+		}
+	case "contains":
+		result = contains(value, argument)
+	}
+
+	return
+}
+
+func contains(value types.AttributeValue, member types.AttributeValue) bool {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		if s, ok := member.(*types.AttributeValueMemberS); ok {
+			return strings.Contains(v.Value, s.Value)
+		}
+	case *types.AttributeValueMemberB:
+		if b, ok := member.(*types.AttributeValueMemberB); ok {
+			return strings.Contains(string(v.Value), string(b.Value))
+		}
+	case *types.AttributeValueMemberSS:
+		if s, ok := member.(*types.AttributeValueMemberS); ok {
+			for _, e := range v.Value {
+				if e == s.Value {
+					return true
+				}
+			}
+		}
+	case *types.AttributeValueMemberNS:
+		if n, ok := member.(*types.AttributeValueMemberN); ok {
+			for _, e := range v.Value {
+				if normalizeNumber(e) == normalizeNumber(n.Value) {
+					return true
+				}
+			}
+		}
+	case *types.AttributeValueMemberBS:
+		if b, ok := member.(*types.AttributeValueMemberB); ok {
+			for _, e := range v.Value {
+				if string(e) == string(b.Value) {
+					return true
+				}
+			}
+		}
+	case *types.AttributeValueMemberL:
+		for _, e := range v.Value {
+			if equal(e, member) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func parseCondition(expression string, ctx *expressionContext) (c condition, err error) {
+	var p *parser
+
+	if p, err = newParser(expression, ctx); nil != err {
+		return
+	}
+	if c, err = p.parseOr(); nil != err {
+		return
+	}
+	err = p.done()
+
+	return
+}
+
+func (p *parser) parseOr() (c condition, err error) {
+	if c, err = p.parseAnd(); nil != err {
+		return
+	}
+
+	for p.isKeyword("OR") {
+		var right condition
+
+		p.next()
+		if right, err = p.parseAnd(); nil != err {
+			return
+		}
+		c = orCondition{left: c, right: right}
+	}
+
+	return
+}
+
+func (p *parser) parseAnd() (c condition, err error) {
+	if c, err = p.parseNot(); nil != err {
+		return
+	}
+
+	for p.isKeyword("AND") {
+		var right condition
+
+		p.next()
+		if right, err = p.parseNot(); nil != err {
+			return
+		}
+		c = andCondition{left: c, right: right}
+	}
+
+	return
+}
+
+func (p *parser) parseNot() (c condition, err error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		if c, err = p.parseNot(); nil != err {
+			return
+		}
+		c = notCondition{condition: c}
+		return
+	}
+
+	return p.parsePrimary()
+}
+
+var conditionFunctions = map[string]bool{
+	"attribute_exists":     false,
+	"attribute_not_exists": false,
+	"attribute_type":       true,
+	"begins_with":          true,
+	"contains":             true,
+}
+
+func (p *parser) parsePrimary() (c condition, err error) {
+	var left operand
+
+	if p.isPunct("(") {
+		p.next()
+		if c, err = p.parseOr(); nil != err {
+			return
+		}
+		err = p.expect(")")
+		return
+	}
+
+	t := p.peek()
+	if hasArgument, ok := conditionFunctions[t.text]; ok && tokenIdent == t.kind && tokenPunct == p.peekAt(1).kind && "(" == p.peekAt(1).text {
+		var function = functionCondition{function: t.text}
+
+		p.next()
+		p.next()
+		if function.path, err = p.parsePath(); nil != err {
+			return
+		}
+		if hasArgument {
+			if err = p.expect(","); nil != err {
+				return
+			}
+			if function.argument, err = p.parseOperand(); nil != err {
+				return
+			}
+		}
+		err = p.expect(")")
+		c = function
+		return
+	}
+
+	if left, err = p.parseOperand(); nil != err {
+		return
+	}
+
+	t = p.peek()
+	switch {
+	case tokenPunct == t.kind && strings.Contains("= <> < <= > >=", t.text) && "" != strings.TrimSpace(t.text):
+		var right operand
+
+		p.next()
+		if right, err = p.parseOperand(); nil != err {
+			return
+		}
+		c = compareCondition{comparator: t.text, left: left, right: right}
+	case p.isKeyword("BETWEEN"):
+		var between = betweenCondition{value: left}
+
+		p.next()
+		if between.low, err = p.parseOperand(); nil != err {
+			return
+		}
+		if !p.isKeyword("AND") {
+			err = p.syntaxError()
+			return
+		}
+		p.next()
+		if between.high, err = p.parseOperand(); nil != err {
+			return
+		}
+		c = between
+	case p.isKeyword("IN"):
+		var in = inCondition{value: left}
+
+		p.next()
+		if err = p.expect("("); nil != err {
+			return
+		}
+		for {
+			var o operand
+
+			if o, err = p.parseOperand(); nil != err {
+				return
+			}
+			in.values = append(in.values, o)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		err = p.expect(")")
+		c = in
+	default:
+		err = p.syntaxError()
+	}
+
+	return
+}
+
+func parseProjection(expression string, ctx *expressionContext) (paths []documentPath, err error) {
+	var p *parser
+
+	if p, err = newParser(expression, ctx); nil != err {
+		return
+	}
+
+	for {
+		var path documentPath
+
+		if path, err = p.parsePath(); nil != err {
+			return
+		}
+		for _, other := range paths {
+			if overlap(other.String(), path.String()) || overlap(path.String(), other.String()) {
+				err = validationError("Invalid ProjectionExpression: Two document paths overlap with each other; path one: %s, path two: %s", other, path)
+				return
+			}
+		}
+		paths = append(paths, path)
+
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+
+	err = p.done()
+
+	return
+}
+
+// project copies the projected paths of it, list elements being collected
+// in projection order as DynamoDB does.
+func project(it item, paths []documentPath) (projected item) {
+	if nil == paths {
+		return copyItem(it)
+	}
+
+	projected = item{}
+
+	for _, path := range paths {
+		var value = path.get(it)
+		var current types.AttributeValue = &types.AttributeValueMemberM{Value: projected}
+
+		if nil == value {
+			continue
+		}
+
+		for i, element := range path {
+			var last = i == len(path)-1
+			var next types.AttributeValue
+
+			if last {
+				next = copyValue(value)
+			} else if path[i+1].isIndex {
+				next = &types.AttributeValueMemberL{}
+			} else {
+				next = &types.AttributeValueMemberM{Value: item{}}
+			}
+
+			switch c := current.(type) {
+			case *types.AttributeValueMemberM:
+				if existing, ok := c.Value[element.name]; ok && !last {
+					next = existing
+				} else {
+					c.Value[element.name] = next
+				}
+			case *types.AttributeValueMemberL:
+				c.Value = append(c.Value, next)
+				next = c.Value[len(c.Value)-1]
+			}
+
+			current = next
+		}
+	}
+
+	return
+}
+
+type updateAction struct {
+	clause string
+	path   documentPath
+	value  operand
+}
+
+type arithmeticOperand struct {
+	operator    string
+	left, right operand
+}
+
+func (o arithmeticOperand) eval(it item) (value types.AttributeValue, err error) {
+	var left, right types.AttributeValue
+	var x, y *big.Rat
+
+	if left, err = evalExisting(o.left, it); nil != err {
+		return
+	}
+	if right, err = evalExisting(o.right, it); nil != err {
+		return
+	}
+
+	l, okLeft := left.(*types.AttributeValueMemberN)
+	r, okRight := right.(*types.AttributeValueMemberN)
+	if !okLeft || !okRight {
+		err = validationError("An operand in the update expression has an incorrect data type")
+		return
+	}
+
+	if x, err = parseNumber(l.Value); nil != err {
+		return
+	}
+	if y, err = parseNumber(r.Value); nil != err {
+		return
+	}
+
+	if "+" == o.operator {
+		value = &types.AttributeValueMemberN{Value: formatNumber(new(big.Rat).Add(x, y))}
+	} else {
+		value = &types.AttributeValueMemberN{Value: formatNumber(new(big.Rat).Sub(x, y))}
+	}
+
+	return
+}
+
+type ifNotExistsOperand struct {
+	path     documentPath
+	fallback operand
+}
+
+func (o ifNotExistsOperand) eval(it item) (value types.AttributeValue, err error) {
+	if value = o.path.get(it); nil != value {
+		return
+	}
+
+	return evalExisting(o.fallback, it)
+}
+
+type listAppendOperand struct {
+	left, right operand
+}
+
+func (o listAppendOperand) eval(it item) (value types.AttributeValue, err error) {
+	var left, right types.AttributeValue
+
+	if left, err = evalExisting(o.left, it); nil != err {
+		return
+	}
+	if right, err = evalExisting(o.right, it); nil != err {
+		return
+	}
+
+	l, okLeft := left.(*types.AttributeValueMemberL)
+	r, okRight := right.(*types.AttributeValueMemberL)
+	if !okLeft || !okRight {
+		err = validationError("An operand in the update expression has an incorrect data type")
+		return
+	}
+
+	values := append(copyValue(l).(*types.AttributeValueMemberL).Value, copyValue(r).(*types.AttributeValueMemberL).Value...)
+	value = &types.AttributeValueMemberL{Value: values}
+
+	return
+}
+
+// evalExisting evaluates an update operand, which must resolve to a value.
+func evalExisting(o operand, it item) (value types.AttributeValue, err error) {
+	if value, err = o.eval(it); nil != err {
+		return
+	}
+	if nil == value {
+		err = validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+
+	return
+}
+
+func (p *parser) parseSetOperand() (o operand, err error) {
+	t := p.peek()
+
+	if tokenIdent == t.kind && tokenPunct == p.peekAt(1).kind && "(" == p.peekAt(1).text {
+		switch t.text {
+		case "if_not_exists":
+			var ifNotExists ifNotExistsOperand
+
+			p.next()
+			p.next()
+			if ifNotExists.path, err = p.parsePath(); nil != err {
+				return
+			}
+			if err = p.expect(","); nil != err {
+				return
+			}
+			if ifNotExists.fallback, err = p.parseSetOperand(); nil != err {
+				return
+			}
+			err = p.expect(")")
+			o = ifNotExists
+			return
+		case "list_append":
+			var listAppend listAppendOperand
+
+			p.next()
+			p.next()
+			if listAppend.left, err = p.parseSetOperand(); nil != err {
+				return
+			}
+			if err = p.expect(","); nil != err {
+				return
+			}
+			if listAppend.right, err = p.parseSetOperand(); nil != err {
+				return
+			}
+			err = p.expect(")")
+			o = listAppend
+			return
+		}
+	}
+
+	return p.parseOperand()
+}
+
+func (p *parser) parseSetValue() (o operand, err error) {
+	if o, err = p.parseSetOperand(); nil != err {
+		return
+	}
+
+	if p.isPunct("+") || p.isPunct("-") {
+		var right operand
+
+		operator := p.next().text
+		if right, err = p.parseSetOperand(); nil != err {
+			return
+		}
+		o = arithmeticOperand{operator: operator, left: o, right: right}
+	}
+
+	return
+}
+
+var updateClauses = []string{"SET", "REMOVE", "ADD", "DELETE"}
+
+func (p *parser) isUpdateClause() bool {
+	for _, clause := range updateClauses {
+		if p.isKeyword(clause) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseUpdate(expression string, ctx *expressionContext) (actions []updateAction, err error) {
+	var p *parser
+	var seen = map[string]bool{}
+
+	if p, err = newParser(expression, ctx); nil != err {
+		return
+	}
+
+	for tokenEOF != p.peek().kind {
+		if !p.isUpdateClause() {
+			err = p.syntaxError()
+			return
+		}
+
+		clause := strings.ToUpper(p.next().text)
+		if seen[clause] {
+			err = validationError("Invalid UpdateExpression: The %s section can only be used once in an update expression", clause)
+			return
+		}
+		seen[clause] = true
+
+		for {
+			var action = updateAction{clause: clause}
+
+			if action.path, err = p.parsePath(); nil != err {
+				return
+			}
+
+			switch clause {
+			case "SET":
+				if err = p.expect("="); nil != err {
+					return
+				}
+				action.value, err = p.parseSetValue()
+			case "ADD", "DELETE":
+				action.value, err = p.parseOperand()
+			}
+			if nil != err {
+				return
+			}
+
+			actions = append(actions, action)
+
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if 0 == len(actions) {
+		err = validationError("Invalid UpdateExpression: The expression can not be empty")
+	}
+
+	return
+}
+
+// applyUpdate applies actions to it in place. Values are computed against
+// the item as it was before the update.
+func applyUpdate(it item, actions []updateAction) (err error) {
+	var before = copyItem(it)
+	var seen = map[string]bool{}
+
+	for _, action := range actions {
+		var path = action.path.String()
+
+		for other := range seen {
+			if overlap(path, other) || overlap(other, path) {
+				err = validationError("Invalid UpdateExpression: Two document paths overlap with each other; path one: %s, path two: %s", other, path)
+				return
+			}
+		}
+		seen[path] = true
+	}
+
+	for _, action := range actions {
+		var value types.AttributeValue
+
+		switch action.clause {
+		case "SET":
+			if value, err = evalExisting(action.value, before); nil != err {
+				return
+			}
+			err = action.path.set(it, copyValue(value))
+		case "REMOVE":
+			action.path.remove(it)
+		case "ADD":
+			if value, err = evalExisting(action.value, before); nil != err {
+				return
+			}
+			err = addValue(it, action.path, value)
+		case "DELETE":
+			if value, err = evalExisting(action.value, before); nil != err {
+				return
+			}
+			err = deleteValue(it, action.path, value)
+		}
+		if nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+// overlap reports whether prefix designates path or one of its parents.
+func overlap(prefix string, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	rest := path[len(prefix):]
+
+	return "" == rest || '.' == rest[0] || '[' == rest[0]
+}
+
+func addValue(it item, path documentPath, value types.AttributeValue) (err error) {
+	var existing = path.get(it)
+
+	if nil == existing {
+		switch value.(type) {
+		case *types.AttributeValueMemberN, *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+			return path.set(it, copyValue(value))
+		}
+		return validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD, operand type: %s", typeOf(value))
+	}
+
+	switch e := existing.(type) {
+	case *types.AttributeValueMemberN:
+		v, ok := value.(*types.AttributeValueMemberN)
+		if !ok {
+			break
+		}
+		x, errX := parseNumber(e.Value)
+		y, errY := parseNumber(v.Value)
+		if nil != errX || nil != errY {
+			break
+		}
+		return path.set(it, &types.AttributeValueMemberN{Value: formatNumber(new(big.Rat).Add(x, y))})
+	case *types.AttributeValueMemberSS:
+		if v, ok := value.(*types.AttributeValueMemberSS); ok {
+			return path.set(it, &types.AttributeValueMemberSS{Value: union(e.Value, v.Value, func(s string) string { return s })})
+		}
+	case *types.AttributeValueMemberNS:
+		if v, ok := value.(*types.AttributeValueMemberNS); ok {
+			return path.set(it, &types.AttributeValueMemberNS{Value: union(e.Value, v.Value, normalizeNumber)})
+		}
+	case *types.AttributeValueMemberBS:
+		if v, ok := value.(*types.AttributeValueMemberBS); ok {
+			members := union(bytesToStrings(e.Value), bytesToStrings(v.Value), func(s string) string { return s })
+			values := make([][]byte, len(members))
+			for i, m := range members {
+				values[i] = []byte(m)
+			}
+			return path.set(it, &types.AttributeValueMemberBS{Value: values})
+		}
+	}
+
+	return validationError("An operand in the update expression has an incorrect data type")
+}
+
+func deleteValue(it item, path documentPath, value types.AttributeValue) (err error) {
+	var existing = path.get(it)
+	var members []string
+
+	if nil == existing {
+		return
+	}
+
+	switch e := existing.(type) {
+	case *types.AttributeValueMemberSS:
+		v, ok := value.(*types.AttributeValueMemberSS)
+		if !ok {
+			break
+		}
+		if members = difference(e.Value, v.Value, func(s string) string { return s }); 0 < len(members) {
+			return path.set(it, &types.AttributeValueMemberSS{Value: members})
+		}
+		path.remove(it)
+		return
+	case *types.AttributeValueMemberNS:
+		v, ok := value.(*types.AttributeValueMemberNS)
+		if !ok {
+			break
+		}
+		if members = difference(e.Value, v.Value, normalizeNumber); 0 < len(members) {
+			return path.set(it, &types.AttributeValueMemberNS{Value: members})
+		}
+		path.remove(it)
+		return
+	case *types.AttributeValueMemberBS:
+		v, ok := value.(*types.AttributeValueMemberBS)
+		if !ok {
+			break
+		}
+		if members = difference(bytesToStrings(e.Value), bytesToStrings(v.Value), func(s string) string { return s }); 0 < len(members) {
+			values := make([][]byte, len(members))
+			for i, m := range members {
+				values[i] = []byte(m)
+			}
+			return path.set(it, &types.AttributeValueMemberBS{Value: values})
+		}
+		path.remove(it)
+		return
+	}
+
+	return validationError("An operand in the update expression has an incorrect data type")
+}
+
+func union(a []string, b []string, normalize func(string) string) (members []string) {
+	var seen = map[string]bool{}
+
+	for _, v := range append(append([]string(nil), a...), b...) {
+		if !seen[normalize(v)] {
+			seen[normalize(v)] = true
+			members = append(members, v)
+		}
+	}
+
+	return
+}
+
+func difference(a []string, b []string, normalize func(string) string) (members []string) {
+	var remove = map[string]bool{}
+
+	for _, v := range b {
+		remove[normalize(v)] = true
+	}
+	for _, v := range a {
+		if !remove[normalize(v)] {
+			members = append(members, v)
+		}
+	}
+
+	return
+}
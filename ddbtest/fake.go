@@ -0,0 +1,645 @@
+// Package ddbtest provides an in-memory DynamoDB to run ddb.Ddb in unit tests
+// without network.
+package ddbtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/seill/ddb"
+)
+
+// Fake is an in-memory DynamoDB. It understands table and index keys, key
+// condition, filter, condition, update and projection expressions, pagination,
+// batches and transactions, and reports an approximate consumed capacity.
+// Tables are ACTIVE as soon as they are created, and so is their time to live,
+// which never expires items. Fake is safe for concurrent use.
+type Fake struct {
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+var (
+	_ ddb.Client      = (*Fake)(nil)
+	_ ddb.TableClient = (*Fake)(nil)
+)
+
+func NewFake() *Fake {
+	return &Fake{
+		tables: map[string]*table{},
+	}
+}
+
+// NewFakeWithTable returns a Fake holding an empty table laid out by DefaultTableInput.
+func NewFakeWithTable(tableName string) *Fake {
+	var f = NewFake()
+
+	if _, err := f.CreateTable(context.TODO(), DefaultTableInput(tableName)); nil != err {
+		panic(err)
+	}
+
+	return f
+}
+
+// DefaultTableInput describes the layout ddb works with, ddb.DefaultTableSchema.
+func DefaultTableInput(tableName string) *dynamodb.CreateTableInput {
+	return ddb.DefaultTableSchema().CreateTableInput(tableName)
+}
+
+func validationError(format string, args ...interface{}) error {
+	return &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+func resourceNotFound(tableName string) error {
+	return &types.ResourceNotFoundException{
+		Message: aws.String(fmt.Sprintf("Requested resource not found: Table: %s not found", tableName)),
+	}
+}
+
+type keySchema struct {
+	hashKey  string
+	rangeKey string
+}
+
+func newKeySchema(elements []types.KeySchemaElement) (schema keySchema, err error) {
+	for _, element := range elements {
+		switch element.KeyType {
+		case types.KeyTypeHash:
+			schema.hashKey = aws.ToString(element.AttributeName)
+		case types.KeyTypeRange:
+			schema.rangeKey = aws.ToString(element.AttributeName)
+		}
+	}
+
+	if "" == schema.hashKey || len(elements) > 2 || ("" == schema.rangeKey && 2 == len(elements)) {
+		err = validationError("Invalid KeySchema: exactly one HASH key and at most one RANGE key are required")
+	}
+
+	return
+}
+
+func (s keySchema) attributes() (attributes []string) {
+	attributes = []string{s.hashKey}
+	if "" != s.rangeKey {
+		attributes = append(attributes, s.rangeKey)
+	}
+
+	return
+}
+
+type index struct {
+	name       string
+	keySchema  keySchema
+	projection types.Projection
+	local      bool
+}
+
+type table struct {
+	description    types.TableDescription
+	keySchema      keySchema
+	attributeTypes map[string]types.ScalarAttributeType
+	indexes        map[string]*index
+	items          map[string]item
+	timeToLive     types.TimeToLiveDescription
+}
+
+// CreateTable creates an ACTIVE table.
+func (f *Fake) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.CreateTableOutput, err error) {
+	var t *table
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tableName := aws.ToString(params.TableName)
+	if _, ok := f.tables[tableName]; ok {
+		err = &types.ResourceInUseException{Message: aws.String(fmt.Sprintf("Table already exists: %s", tableName))}
+		return
+	}
+
+	if t, err = newTable(params); nil != err {
+		return
+	}
+	f.tables[tableName] = t
+
+	output = &dynamodb.CreateTableOutput{TableDescription: t.describe()}
+
+	return
+}
+
+func newTable(params *dynamodb.CreateTableInput) (t *table, err error) {
+	var used = map[string]bool{}
+
+	t = &table{
+		attributeTypes: map[string]types.ScalarAttributeType{},
+		indexes:        map[string]*index{},
+		items:          map[string]item{},
+	}
+
+	if "" == aws.ToString(params.TableName) {
+		err = validationError("TableName must be specified")
+		return
+	}
+
+	for _, definition := range params.AttributeDefinitions {
+		t.attributeTypes[aws.ToString(definition.AttributeName)] = definition.AttributeType
+	}
+
+	if t.keySchema, err = newKeySchema(params.KeySchema); nil != err {
+		return
+	}
+	for _, attribute := range t.keySchema.attributes() {
+		used[attribute] = true
+	}
+
+	addIndex := func(name *string, elements []types.KeySchemaElement, projection *types.Projection, local bool) (err error) {
+		var x = &index{name: aws.ToString(name), local: local}
+
+		if _, ok := t.indexes[x.name]; ok || "" == x.name {
+			return validationError("Invalid index name: %s", x.name)
+		}
+		if x.keySchema, err = newKeySchema(elements); nil != err {
+			return
+		}
+		if local && x.keySchema.hashKey != t.keySchema.hashKey {
+			return validationError("Local secondary index %s must have the same hash key as the table", x.name)
+		}
+		if nil != projection {
+			x.projection = *projection
+		}
+		if "" == x.projection.ProjectionType {
+			return validationError("Projection type must be specified for index %s", x.name)
+		}
+		for _, attribute := range x.keySchema.attributes() {
+			used[attribute] = true
+		}
+		t.indexes[x.name] = x
+
+		return
+	}
+
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		if err = addIndex(gsi.IndexName, gsi.KeySchema, gsi.Projection, false); nil != err {
+			return
+		}
+	}
+	for _, lsi := range params.LocalSecondaryIndexes {
+		if err = addIndex(lsi.IndexName, lsi.KeySchema, lsi.Projection, true); nil != err {
+			return
+		}
+	}
+
+	for attribute := range used {
+		if _, ok := t.attributeTypes[attribute]; !ok {
+			err = validationError("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s]", attribute)
+			return
+		}
+	}
+	if len(used) != len(t.attributeTypes) {
+		err = validationError("One or more parameter values were invalid: Number of attributes in KeySchema does not exactly match number of attributes defined in AttributeDefinitions")
+		return
+	}
+
+	now := time.Now()
+	t.description = types.TableDescription{
+		TableName:            params.TableName,
+		TableArn:             aws.String("arn:aws:dynamodb:local:000000000000:table/" + aws.ToString(params.TableName)),
+		TableStatus:          types.TableStatusActive,
+		CreationDateTime:     &now,
+		KeySchema:            params.KeySchema,
+		AttributeDefinitions: params.AttributeDefinitions,
+	}
+	if "" != params.BillingMode {
+		t.description.BillingModeSummary = &types.BillingModeSummary{BillingMode: params.BillingMode}
+	}
+	if nil != params.ProvisionedThroughput {
+		t.description.ProvisionedThroughput = &types.ProvisionedThroughputDescription{
+			ReadCapacityUnits:  params.ProvisionedThroughput.ReadCapacityUnits,
+			WriteCapacityUnits: params.ProvisionedThroughput.WriteCapacityUnits,
+		}
+	}
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		t.description.GlobalSecondaryIndexes = append(t.description.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   gsi.IndexName,
+			IndexStatus: types.IndexStatusActive,
+			KeySchema:   gsi.KeySchema,
+			Projection:  gsi.Projection,
+		})
+	}
+	for _, lsi := range params.LocalSecondaryIndexes {
+		t.description.LocalSecondaryIndexes = append(t.description.LocalSecondaryIndexes, types.LocalSecondaryIndexDescription{
+			IndexName:  lsi.IndexName,
+			KeySchema:  lsi.KeySchema,
+			Projection: lsi.Projection,
+		})
+	}
+
+	return
+}
+
+func (t *table) describe() *types.TableDescription {
+	var description = t.description
+	var size int
+
+	for _, it := range t.items {
+		size += itemSize(it)
+	}
+	description.ItemCount = aws.Int64(int64(len(t.items)))
+	description.TableSizeBytes = aws.Int64(int64(size))
+
+	return &description
+}
+
+func (f *Fake) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.DescribeTableOutput, err error) {
+	var t *table
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, err = f.table(params.TableName); nil != err {
+		return
+	}
+
+	output = &dynamodb.DescribeTableOutput{Table: t.describe()}
+
+	return
+}
+
+// DeleteTable removes the table and its items at once.
+func (f *Fake) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.DeleteTableOutput, err error) {
+	var t *table
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, err = f.table(params.TableName); nil != err {
+		return
+	}
+	delete(f.tables, aws.ToString(params.TableName))
+
+	description := t.describe()
+	description.TableStatus = types.TableStatusDeleting
+	output = &dynamodb.DeleteTableOutput{TableDescription: description}
+
+	return
+}
+
+func (f *Fake) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.UpdateTimeToLiveOutput, err error) {
+	var t *table
+	var specification = params.TimeToLiveSpecification
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, err = f.table(params.TableName); nil != err {
+		return
+	}
+
+	if nil == specification || "" == aws.ToString(specification.AttributeName) || nil == specification.Enabled {
+		err = validationError("TimeToLiveSpecification requires AttributeName and Enabled")
+		return
+	}
+
+	enabled := types.TimeToLiveStatusEnabled == t.timeToLive.TimeToLiveStatus
+	switch {
+	case *specification.Enabled && enabled:
+		err = validationError("TimeToLive is already enabled")
+		return
+	case !*specification.Enabled && !enabled:
+		err = validationError("TimeToLive is already disabled")
+		return
+	case *specification.Enabled:
+		t.timeToLive = types.TimeToLiveDescription{AttributeName: specification.AttributeName, TimeToLiveStatus: types.TimeToLiveStatusEnabled}
+	default:
+		t.timeToLive = types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	}
+
+	output = &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: specification}
+
+	return
+}
+
+func (f *Fake) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.DescribeTimeToLiveOutput, err error) {
+	var t *table
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, err = f.table(params.TableName); nil != err {
+		return
+	}
+
+	description := t.timeToLive
+	if "" == description.TimeToLiveStatus {
+		description.TimeToLiveStatus = types.TimeToLiveStatusDisabled
+	}
+	output = &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &description}
+
+	return
+}
+
+// Items returns a copy of every item of tableName, ordered by key.
+func (f *Fake) Items(tableName string) (items []map[string]types.AttributeValue) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tables[tableName]
+	if !ok {
+		return nil
+	}
+
+	for _, key := range sortedKeys(t.items) {
+		items = append(items, copyItem(t.items[key]))
+	}
+
+	return
+}
+
+func (f *Fake) table(tableName *string) (t *table, err error) {
+	var ok bool
+
+	if t, ok = f.tables[aws.ToString(tableName)]; !ok {
+		err = resourceNotFound(aws.ToString(tableName))
+	}
+
+	return
+}
+
+func (t *table) index(indexName *string) (x *index, err error) {
+	var ok bool
+
+	if nil == indexName {
+		return
+	}
+
+	if x, ok = t.indexes[*indexName]; !ok {
+		err = validationError("The table does not have the specified index: %s", *indexName)
+	}
+
+	return
+}
+
+func (t *table) checkKeyValue(name string, value types.AttributeValue) (err error) {
+	var expected = string(t.attributeTypes[name])
+
+	if typeOf(value) != expected {
+		return validationError("One or more parameter values were invalid: Type mismatch for key %s expected: %s actual: %s", name, expected, typeOf(value))
+	}
+
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		if "" == v.Value {
+			return validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name)
+		}
+	case *types.AttributeValueMemberB:
+		if 0 == len(v.Value) {
+			return validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty binary value. Key: %s", name)
+		}
+	}
+
+	return
+}
+
+// checkKey validates a key given to GetItem, UpdateItem, DeleteItem and the like,
+// which must hold exactly the table key attributes.
+func (t *table) checkKey(key item) (err error) {
+	var attributes = t.keySchema.attributes()
+
+	if len(key) != len(attributes) {
+		return validationError("The provided key element does not match the schema")
+	}
+
+	for _, attribute := range attributes {
+		value, ok := key[attribute]
+		if !ok {
+			return validationError("The provided key element does not match the schema")
+		}
+		if err = t.checkKeyValue(attribute, value); nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+// checkItem validates the table and index keys of an item about to be written.
+func (t *table) checkItem(it item) (err error) {
+	for _, attribute := range t.keySchema.attributes() {
+		value, ok := it[attribute]
+		if !ok {
+			return validationError("One or more parameter values were invalid: Missing the key %s in the item", attribute)
+		}
+		if err = t.checkKeyValue(attribute, value); nil != err {
+			return
+		}
+	}
+
+	for _, indexName := range sortedKeys(t.indexes) {
+		for _, attribute := range t.indexes[indexName].keySchema.attributes() {
+			if value, ok := it[attribute]; ok {
+				if err = t.checkKeyValue(attribute, value); nil != err {
+					return
+				}
+			}
+		}
+	}
+
+	if itemSize(it) > 400*1024 {
+		return validationError("Item size has exceeded the maximum allowed size")
+	}
+
+	return
+}
+
+func (t *table) encodeKey(it item) string {
+	var parts []string
+
+	for _, attribute := range t.keySchema.attributes() {
+		parts = append(parts, encodeKeyValue(it[attribute]))
+	}
+
+	return strings.Join(parts, "|")
+}
+
+func (t *table) keyOf(it item) (key item) {
+	key = item{}
+
+	for _, attribute := range t.keySchema.attributes() {
+		if value, ok := it[attribute]; ok {
+			key[attribute] = copyValue(value)
+		}
+	}
+
+	return
+}
+
+// schema returns the key schema of x, or of the table for a nil index.
+func (t *table) schema(x *index) keySchema {
+	if nil == x {
+		return t.keySchema
+	}
+
+	return x.keySchema
+}
+
+// lastEvaluatedKey returns the table and index key attributes of it.
+func (t *table) lastEvaluatedKey(x *index, it item) (key item) {
+	key = t.keyOf(it)
+
+	for _, attribute := range t.schema(x).attributes() {
+		if value, ok := it[attribute]; ok {
+			key[attribute] = copyValue(value)
+		}
+	}
+
+	return
+}
+
+// compare orders items by index hash key, index range key, then table key.
+func (t *table) compare(x *index, a item, b item) int {
+	var schema = t.schema(x)
+
+	if c := strings.Compare(encodeKeyValue(a[schema.hashKey]), encodeKeyValue(b[schema.hashKey])); 0 != c {
+		return c
+	}
+
+	if "" != schema.rangeKey {
+		if c, ok := compareScalar(a[schema.rangeKey], b[schema.rangeKey]); ok && 0 != c {
+			return c
+		}
+	}
+
+	return strings.Compare(t.encodeKey(a), t.encodeKey(b))
+}
+
+// entries returns the items of the table, or of index x, as stored in it,
+// ordered by compare.
+func (t *table) entries(x *index) (entries []item) {
+	for _, it := range t.items {
+		if nil != x {
+			var indexed = true
+
+			for _, attribute := range x.keySchema.attributes() {
+				if _, ok := it[attribute]; !ok {
+					indexed = false
+				}
+			}
+			if !indexed {
+				continue
+			}
+			it = t.projectIndex(x, it)
+		}
+
+		entries = append(entries, it)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return 0 > t.compare(x, entries[i], entries[j])
+	})
+
+	return
+}
+
+func (t *table) projectIndex(x *index, it item) (projected item) {
+	if types.ProjectionTypeAll == x.projection.ProjectionType {
+		return it
+	}
+
+	projected = t.lastEvaluatedKey(x, it)
+	if types.ProjectionTypeInclude == x.projection.ProjectionType {
+		for _, attribute := range x.projection.NonKeyAttributes {
+			if value, ok := it[attribute]; ok {
+				projected[attribute] = value
+			}
+		}
+	}
+
+	return
+}
+
+func readCapacity(t *table, x *index, size int, consistent bool, mode types.ReturnConsumedCapacity) *types.ConsumedCapacity {
+	var units = float64((size + 4095) / 4096)
+
+	if 0 == units {
+		units = 1
+	}
+	if !consistent {
+		units /= 2
+	}
+
+	return consumedCapacity(t, x, units, 0, mode)
+}
+
+func writeCapacity(t *table, size int, mode types.ReturnConsumedCapacity) *types.ConsumedCapacity {
+	var units = float64((size + 1023) / 1024)
+
+	if 0 == units {
+		units = 1
+	}
+
+	return consumedCapacity(t, nil, 0, units, mode)
+}
+
+func consumedCapacity(t *table, x *index, read float64, write float64, mode types.ReturnConsumedCapacity) (consumed *types.ConsumedCapacity) {
+	var units = read + write
+
+	if "" == mode || types.ReturnConsumedCapacityNone == mode {
+		return nil
+	}
+
+	consumed = &types.ConsumedCapacity{
+		TableName:     t.description.TableName,
+		CapacityUnits: aws.Float64(units),
+	}
+	if 0 < read {
+		consumed.ReadCapacityUnits = aws.Float64(read)
+	}
+	if 0 < write {
+		consumed.WriteCapacityUnits = aws.Float64(write)
+	}
+
+	if types.ReturnConsumedCapacityIndexes == mode {
+		capacity := types.Capacity{CapacityUnits: aws.Float64(units)}
+		switch {
+		case nil == x:
+			consumed.Table = &capacity
+		case x.local:
+			consumed.LocalSecondaryIndexes = map[string]types.Capacity{x.name: capacity}
+		default:
+			consumed.GlobalSecondaryIndexes = map[string]types.Capacity{x.name: capacity}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,219 @@
+package ddbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+func s(value string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: value}
+}
+
+func n(value string) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: value}
+}
+
+func TestConditionExpression(t *testing.T) {
+	it := item{
+		"Name":  s("Alice"),
+		"Age":   n("30"),
+		"Tags":  &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"Items": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberM{Value: item{"Qty": n("2")}}}},
+	}
+	values := map[string]types.AttributeValue{":name": s("Al"), ":age": n("30.0"), ":low": n("18"), ":high": n("65"), ":tag": s("b"), ":type": s("SS"), ":two": n("2")}
+
+	tests := map[string]bool{
+		"begins_with(#n, :name) AND Age = :age":                   true,
+		"Age BETWEEN :low AND :high AND NOT contains(Tags, :tag)": false,
+		"attribute_not_exists(Missing) OR Age < :low":             true,
+		"attribute_type(Tags, :type) AND size(Tags) = :two":       true,
+		"Items[0].Qty IN (:low, :two)":                            true,
+		"(Age > :high OR Age <> :age) AND attribute_exists(Name)": false,
+	}
+
+	for expression, expected := range tests {
+		ctx := newExpressionContext(map[string]string{"#n": "Name"}, values)
+		c, err := parseCondition(expression, ctx)
+		if nil != err {
+			t.Errorf("%s: %v", expression, err)
+			continue
+		}
+		if got, err := c.eval(it); nil != err || expected != got {
+			t.Errorf("%s: expected %v, got %v %v", expression, expected, got, err)
+		}
+	}
+
+	if _, err := parseCondition("Age = :missing", newExpressionContext(nil, values)); nil == err {
+		t.Error("expected an undefined value error")
+	}
+	if _, err := parseCondition("Age = = :age", newExpressionContext(nil, values)); nil == err {
+		t.Error("expected a syntax error")
+	}
+}
+
+func TestUpdateExpression(t *testing.T) {
+	it := item{
+		"Count": n("1"),
+		"List":  &types.AttributeValueMemberL{Value: []types.AttributeValue{s("a")}},
+		"Tags":  &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"Old":   s("x"),
+		"Map":   &types.AttributeValueMemberM{Value: item{}},
+	}
+	values := map[string]types.AttributeValue{
+		":one":  n("1"),
+		":zero": n("0"),
+		":list": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("b")}},
+		":tags": &types.AttributeValueMemberSS{Value: []string{"a"}},
+		":v":    s("v"),
+	}
+
+	ctx := newExpressionContext(nil, values)
+	actions, err := parseUpdate("SET Count = Count + :one, Total = if_not_exists(Total, :zero) - :one, List = list_append(List, :list), Map.Key = :v REMOVE Old DELETE Tags :tags", ctx)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err = ctx.checkUnused(); nil != err {
+		t.Fatal(err)
+	}
+	if err = applyUpdate(it, actions); nil != err {
+		t.Fatal(err)
+	}
+
+	expected := item{
+		"Count": n("2"),
+		"Total": n("-1"),
+		"List":  &types.AttributeValueMemberL{Value: []types.AttributeValue{s("a"), s("b")}},
+		"Tags":  &types.AttributeValueMemberSS{Value: []string{"b"}},
+		"Map":   &types.AttributeValueMemberM{Value: item{"Key": s("v")}},
+	}
+	if !equal(&types.AttributeValueMemberM{Value: expected}, &types.AttributeValueMemberM{Value: it}) {
+		t.Errorf("unexpected item: %v", it)
+	}
+
+	actions, _ = parseUpdate("SET Map.Key = :v, Map = :v", newExpressionContext(nil, values))
+	if err = applyUpdate(it, actions); nil == err {
+		t.Error("expected overlapping paths to be rejected")
+	}
+	actions, _ = parseUpdate("SET Missing.Key = :v", newExpressionContext(nil, values))
+	if err = applyUpdate(it, actions); nil == err {
+		t.Error("expected a missing parent to be rejected")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.TODO()
+	f := NewFakeWithTable("table")
+
+	for _, sk := range []string{"B", "A", "D", "C", "E"} {
+		it := item{"PK": s("USER"), "SK": s(sk), "Kind": s("user")}
+		if "C" != sk {
+			it["GSI1PK"] = s("KIND")
+			it["GSI1SK"] = s(sk)
+		}
+		if _, err := f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("table"), Item: it}); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String("table"),
+		KeyConditionExpression:    aws.String("PK = :pk AND SK > :sk"),
+		FilterExpression:          aws.String("SK <> :skip"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("USER"), ":sk": s("A"), ":skip": s("C")},
+		Limit:                     aws.Int32(2),
+		ScanIndexForward:          aws.Bool(false),
+	}
+
+	var keys []string
+	var pages int
+	for {
+		output, err := f.Query(ctx, input)
+		if nil != err {
+			t.Fatal(err)
+		}
+		pages++
+		for _, it := range output.Items {
+			keys = append(keys, it["SK"].(*types.AttributeValueMemberS).Value)
+		}
+		if nil == output.LastEvaluatedKey {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	// the limit bounds evaluated items and a full last page still yields a key
+	if "[E D B]" != fmt.Sprint(keys) || 3 != pages {
+		t.Errorf("unexpected pages: %v in %d pages", keys, pages)
+	}
+
+	output, err := f.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String("table"),
+		IndexName:                 aws.String("GSI1"),
+		KeyConditionExpression:    aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("KIND")},
+		Select:                    types.SelectCount,
+	})
+	if nil != err || 4 != output.Count || nil != output.Items {
+		t.Errorf("expected a sparse index of 4 items, got %v %v", output, err)
+	}
+
+	invalid := []*dynamodb.QueryInput{
+		{TableName: aws.String("table"), IndexName: aws.String("GSI1"), ConsistentRead: aws.Bool(true), KeyConditionExpression: aws.String("GSI1PK = :pk"), ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("KIND")}},
+		{TableName: aws.String("table"), KeyConditionExpression: aws.String("SK = :pk"), ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("KIND")}},
+		{TableName: aws.String("table"), KeyConditionExpression: aws.String("PK = :pk"), ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("KIND"), ":unused": s("x")}},
+	}
+	for _, input := range invalid {
+		var apiError smithy.APIError
+		if _, err = f.Query(ctx, input); !errors.As(err, &apiError) || "ValidationException" != apiError.ErrorCode() {
+			t.Errorf("expected a ValidationException, got %v", err)
+		}
+	}
+}
+
+func TestTransactWriteItems(t *testing.T) {
+	ctx := context.TODO()
+	f := NewFakeWithTable("table")
+	key := item{"PK": s("USER#1"), "SK": s("PROFILE")}
+
+	_, err := f.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("table"), Item: item{"PK": s("USER#1"), "SK": s("PROFILE"), "Balance": n("10")}})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	_, err = f.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String("table"), Item: item{"PK": s("USER#2"), "SK": s("PROFILE")}}},
+		{Update: &types.Update{
+			TableName:                 aws.String("table"),
+			Key:                       key,
+			UpdateExpression:          aws.String("SET Balance = Balance - :amount"),
+			ConditionExpression:       aws.String("Balance >= :amount"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":amount": n("20")},
+		}},
+	}})
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) || "None" != *canceled.CancellationReasons[0].Code || "ConditionalCheckFailed" != *canceled.CancellationReasons[1].Code {
+		t.Fatalf("expected a canceled transaction, got %v", err)
+	}
+	if 1 != len(f.Items("table")) {
+		t.Errorf("expected nothing to be written, got %v", f.Items("table"))
+	}
+
+	_, err = f.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String("table"), Item: item{"PK": s("USER#2"), "SK": s("PROFILE")}, ConditionExpression: aws.String("attribute_not_exists(PK)")}},
+		{Update: &types.Update{TableName: aws.String("table"), Key: key, UpdateExpression: aws.String("ADD Balance :amount"), ExpressionAttributeValues: map[string]types.AttributeValue{":amount": n("-2.5")}}},
+	}})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	output, err := f.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("table"), Key: key, ProjectionExpression: aws.String("Balance")})
+	if nil != err || "7.5" != output.Item["Balance"].(*types.AttributeValueMemberN).Value || 1 != len(output.Item) {
+		t.Errorf("unexpected item: %v %v", output, err)
+	}
+}
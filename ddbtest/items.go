@@ -0,0 +1,1047 @@
+package ddbtest
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const maxPageSize = 1024 * 1024
+
+type writeKind int
+
+const (
+	writePut writeKind = iota
+	writeUpdate
+	writeDelete
+	writeCheck
+)
+
+// writeRequest is the common shape of single item writes, whether sent alone,
+// in a batch or in a transaction.
+type writeRequest struct {
+	kind            writeKind
+	tableName       *string
+	key             item
+	item            item
+	update          *string
+	condition       *string
+	names           map[string]string
+	values          map[string]types.AttributeValue
+	returnOnFailure types.ReturnValuesOnConditionCheckFailure
+}
+
+// write is a change to one item, prepared under the lock and applied once
+// every condition of the request holds.
+type write struct {
+	table   *table
+	key     string
+	old     item
+	new     item
+	updated []documentPath
+	check   bool
+}
+
+func (w write) apply() {
+	switch {
+	case w.check:
+	case nil == w.new:
+		delete(w.table.items, w.key)
+	default:
+		w.table.items[w.key] = w.new
+	}
+}
+
+func (w write) size() int {
+	return max(itemSize(w.old), itemSize(w.new))
+}
+
+func compileCondition(ctx *expressionContext, expression *string) (c condition, err error) {
+	if nil == expression {
+		return
+	}
+
+	return parseCondition(*expression, ctx)
+}
+
+func compileProjection(ctx *expressionContext, expression *string) (paths []documentPath, err error) {
+	if nil == expression {
+		return
+	}
+
+	return parseProjection(*expression, ctx)
+}
+
+func holds(c condition, it item) (bool, error) {
+	if nil == c {
+		return true, nil
+	}
+	if nil == it {
+		it = item{}
+	}
+
+	return c.eval(it)
+}
+
+func conditionFailed(old item, returnOnFailure types.ReturnValuesOnConditionCheckFailure) error {
+	var err = &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+
+	if types.ReturnValuesOnConditionCheckFailureAllOld == returnOnFailure && nil != old {
+		err.Item = copyItem(old)
+	}
+
+	return err
+}
+
+func (f *Fake) prepare(request writeRequest) (w write, err error) {
+	var ctx = newExpressionContext(request.names, request.values)
+	var c condition
+	var actions []updateAction
+	var ok bool
+
+	if w.table, err = f.table(request.tableName); nil != err {
+		return
+	}
+
+	if c, err = compileCondition(ctx, request.condition); nil != err {
+		return
+	}
+	if writeUpdate == request.kind && nil != request.update {
+		if actions, err = parseUpdate(*request.update, ctx); nil != err {
+			return
+		}
+	}
+	if err = ctx.checkUnused(); nil != err {
+		return
+	}
+
+	if writePut == request.kind {
+		w.new = copyItem(request.item)
+		if err = w.table.checkItem(w.new); nil != err {
+			return
+		}
+		w.key = w.table.encodeKey(w.new)
+	} else {
+		if err = w.table.checkKey(request.key); nil != err {
+			return
+		}
+		w.key = w.table.encodeKey(request.key)
+	}
+
+	if w.old, ok = w.table.items[w.key]; !ok {
+		w.old = nil
+	}
+
+	if ok, err = holds(c, w.old); nil != err {
+		return
+	}
+	if !ok {
+		err = conditionFailed(w.old, request.returnOnFailure)
+		return
+	}
+
+	switch request.kind {
+	case writeUpdate:
+		for _, action := range actions {
+			if slices.Contains(w.table.keySchema.attributes(), action.path[0].name) {
+				err = validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", action.path[0].name)
+				return
+			}
+			w.updated = append(w.updated, action.path)
+		}
+
+		if nil == w.old {
+			w.new = copyItem(request.key)
+		} else {
+			w.new = copyItem(w.old)
+		}
+		if err = applyUpdate(w.new, actions); nil != err {
+			return
+		}
+		err = w.table.checkItem(w.new)
+	case writeCheck:
+		if nil == c {
+			err = validationError("The ConditionExpression of a ConditionCheck must be specified")
+		}
+		w.check = true
+	}
+
+	return
+}
+
+func (f *Fake) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.GetItemOutput, err error) {
+	var t *table
+	var paths []documentPath
+	var expressionContext = newExpressionContext(params.ExpressionAttributeNames, nil)
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, err = f.table(params.TableName); nil != err {
+		return
+	}
+	if err = t.checkKey(params.Key); nil != err {
+		return
+	}
+	if paths, err = compileProjection(expressionContext, params.ProjectionExpression); nil != err {
+		return
+	}
+	if err = expressionContext.checkUnused(); nil != err {
+		return
+	}
+
+	output = &dynamodb.GetItemOutput{}
+
+	it := t.items[t.encodeKey(params.Key)]
+	if nil != it {
+		output.Item = project(it, paths)
+	}
+	output.ConsumedCapacity = readCapacity(t, nil, itemSize(it), aws.ToBool(params.ConsistentRead), params.ReturnConsumedCapacity)
+
+	return
+}
+
+func (f *Fake) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.PutItemOutput, err error) {
+	var w write
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	switch params.ReturnValues {
+	case "", types.ReturnValueNone, types.ReturnValueAllOld:
+	default:
+		err = validationError("ReturnValues can only be ALL_OLD or NONE")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w, err = f.prepare(writeRequest{
+		kind:            writePut,
+		tableName:       params.TableName,
+		item:            params.Item,
+		condition:       params.ConditionExpression,
+		names:           params.ExpressionAttributeNames,
+		values:          params.ExpressionAttributeValues,
+		returnOnFailure: params.ReturnValuesOnConditionCheckFailure,
+	})
+	if nil != err {
+		return
+	}
+	w.apply()
+
+	output = &dynamodb.PutItemOutput{
+		ConsumedCapacity: writeCapacity(w.table, w.size(), params.ReturnConsumedCapacity),
+	}
+	if types.ReturnValueAllOld == params.ReturnValues {
+		output.Attributes = copyItem(w.old)
+	}
+
+	return
+}
+
+// UpdateItem creates the item when it does not exist, as DynamoDB does.
+func (f *Fake) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.UpdateItemOutput, err error) {
+	var w write
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w, err = f.prepare(writeRequest{
+		kind:            writeUpdate,
+		tableName:       params.TableName,
+		key:             params.Key,
+		update:          params.UpdateExpression,
+		condition:       params.ConditionExpression,
+		names:           params.ExpressionAttributeNames,
+		values:          params.ExpressionAttributeValues,
+		returnOnFailure: params.ReturnValuesOnConditionCheckFailure,
+	})
+	if nil != err {
+		return
+	}
+
+	output = &dynamodb.UpdateItemOutput{}
+
+	switch params.ReturnValues {
+	case "", types.ReturnValueNone:
+	case types.ReturnValueAllOld:
+		output.Attributes = copyItem(w.old)
+	case types.ReturnValueAllNew:
+		output.Attributes = copyItem(w.new)
+	case types.ReturnValueUpdatedOld:
+		if nil != w.old && 0 < len(w.updated) {
+			output.Attributes = project(w.old, w.updated)
+		}
+	case types.ReturnValueUpdatedNew:
+		if 0 < len(w.updated) {
+			output.Attributes = project(w.new, w.updated)
+		}
+	default:
+		err = validationError("Invalid ReturnValues: %s", params.ReturnValues)
+		output = nil
+		return
+	}
+	if 0 == len(output.Attributes) {
+		output.Attributes = nil
+	}
+
+	w.apply()
+	output.ConsumedCapacity = writeCapacity(w.table, w.size(), params.ReturnConsumedCapacity)
+
+	return
+}
+
+func (f *Fake) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.DeleteItemOutput, err error) {
+	var w write
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	switch params.ReturnValues {
+	case "", types.ReturnValueNone, types.ReturnValueAllOld:
+	default:
+		err = validationError("ReturnValues can only be ALL_OLD or NONE")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w, err = f.prepare(writeRequest{
+		kind:            writeDelete,
+		tableName:       params.TableName,
+		key:             params.Key,
+		condition:       params.ConditionExpression,
+		names:           params.ExpressionAttributeNames,
+		values:          params.ExpressionAttributeValues,
+		returnOnFailure: params.ReturnValuesOnConditionCheckFailure,
+	})
+	if nil != err {
+		return
+	}
+	w.apply()
+
+	output = &dynamodb.DeleteItemOutput{
+		ConsumedCapacity: writeCapacity(w.table, w.size(), params.ReturnConsumedCapacity),
+	}
+	if types.ReturnValueAllOld == params.ReturnValues {
+		output.Attributes = copyItem(w.old)
+	}
+
+	return
+}
+
+// readRequest is what Query and Scan share once the candidate items are known.
+type readRequest struct {
+	exclusiveStartKey item
+	limit             *int32
+	filter            condition
+	projection        []documentPath
+	selectCount       bool
+	forward           bool
+}
+
+type readResult struct {
+	items            []item
+	count            int32
+	scannedCount     int32
+	lastEvaluatedKey item
+	size             int
+}
+
+func checkSelect(x *index, selectValue types.Select, projection *string) (err error) {
+	switch selectValue {
+	case "":
+		return
+	case types.SelectAllProjectedAttributes:
+	case types.SelectSpecificAttributes:
+		if nil == projection {
+			err = validationError("Select SPECIFIC_ATTRIBUTES requires a ProjectionExpression")
+		}
+		return
+	case types.SelectCount:
+	case types.SelectAllAttributes:
+		if nil != x && !x.local && types.ProjectionTypeAll != x.projection.ProjectionType {
+			err = validationError("One or more parameter values were invalid: Select type ALL_ATTRIBUTES is not supported for global secondary index %s because its projection type is not ALL", x.name)
+			return
+		}
+	default:
+		return validationError("Invalid Select: %s", selectValue)
+	}
+
+	if nil != projection {
+		err = validationError("Cannot specify the ProjectionExpression when choosing to get %s", selectValue)
+	}
+
+	return
+}
+
+func (t *table) checkStartKey(x *index, key item) (err error) {
+	var attributes = t.keySchema.attributes()
+
+	if nil == key {
+		return
+	}
+
+	if nil != x {
+		for _, attribute := range x.keySchema.attributes() {
+			if !slices.Contains(attributes, attribute) {
+				attributes = append(attributes, attribute)
+			}
+		}
+	}
+
+	if len(key) != len(attributes) {
+		return validationError("The provided starting key is invalid: The provided key element does not match the schema")
+	}
+	for _, attribute := range attributes {
+		value, ok := key[attribute]
+		if !ok {
+			return validationError("The provided starting key is invalid: The provided key element does not match the schema")
+		}
+		if err = t.checkKeyValue(attribute, value); nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+// read evaluates entries, in the order of the request, from the exclusive start
+// key up to the limit or a page of 1MB. The limit bounds evaluated items,
+// before the filter, and reaching it always yields a LastEvaluatedKey.
+func (t *table) read(x *index, entries []item, request readRequest) (result readResult, err error) {
+	if nil != request.limit && 0 >= *request.limit {
+		err = validationError("Limit must be greater than or equal to 1")
+		return
+	}
+	if err = t.checkStartKey(x, request.exclusiveStartKey); nil != err {
+		return
+	}
+
+	if !request.forward {
+		slices.Reverse(entries)
+	}
+
+	if nil != request.exclusiveStartKey {
+		var start = len(entries)
+
+		for i, entry := range entries {
+			c := t.compare(x, entry, request.exclusiveStartKey)
+			if (request.forward && 0 < c) || (!request.forward && 0 > c) {
+				start = i
+				break
+			}
+		}
+		entries = entries[start:]
+	}
+
+	for i, entry := range entries {
+		var match bool
+
+		result.scannedCount++
+		result.size += itemSize(entry)
+
+		if match, err = holds(request.filter, entry); nil != err {
+			return
+		}
+		if match {
+			result.count++
+			if !request.selectCount {
+				result.items = append(result.items, project(entry, request.projection))
+			}
+		}
+
+		limitReached := nil != request.limit && result.scannedCount == *request.limit
+		if limitReached || (maxPageSize <= result.size && i < len(entries)-1) {
+			result.lastEvaluatedKey = t.lastEvaluatedKey(x, entry)
+			break
+		}
+	}
+
+	return
+}
+
+// checkKeyCondition accepts an equality on the hash key, optionally AND a
+// single comparison, BETWEEN or begins_with on the range key.
+func checkKeyCondition(c condition, schema keySchema) (err error) {
+	var parts []condition
+	var hashKey, rangeKey bool
+	var flatten func(c condition)
+
+	flatten = func(c condition) {
+		if and, ok := c.(andCondition); ok {
+			flatten(and.left)
+			flatten(and.right)
+			return
+		}
+		parts = append(parts, c)
+	}
+	flatten(c)
+
+	keyName := func(o operand) string {
+		if p, ok := o.(pathOperand); ok && 1 == len(p.path) {
+			return p.path[0].name
+		}
+		return ""
+	}
+	isValue := func(o operand) bool {
+		_, ok := o.(valueOperand)
+		return ok
+	}
+
+	for _, part := range parts {
+		var name string
+		var valid bool
+
+		switch p := part.(type) {
+		case compareCondition:
+			name = keyName(p.left)
+			valid = isValue(p.right) && "<>" != p.comparator && (name == schema.rangeKey || "=" == p.comparator)
+		case betweenCondition:
+			name = keyName(p.value)
+			valid = isValue(p.low) && isValue(p.high) && name == schema.rangeKey
+		case functionCondition:
+			name = p.path.String()
+			valid = "begins_with" == p.function && name == schema.rangeKey
+		}
+
+		switch {
+		case !valid || "" == name:
+			return validationError("Invalid KeyConditionExpression: only key attributes can be used, with = on the hash key")
+		case name == schema.hashKey && !hashKey:
+			hashKey = true
+		case name == schema.rangeKey && "" != name && !rangeKey:
+			rangeKey = true
+		default:
+			return validationError("Query key condition not supported")
+		}
+	}
+
+	if !hashKey {
+		err = validationError("Query condition missed key schema element: %s", schema.hashKey)
+	}
+
+	return
+}
+
+func (f *Fake) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.QueryOutput, err error) {
+	var t *table
+	var x *index
+	var keyCondition condition
+	var request = readRequest{
+		exclusiveStartKey: params.ExclusiveStartKey,
+		limit:             params.Limit,
+		selectCount:       types.SelectCount == params.Select,
+		forward:           nil == params.ScanIndexForward || *params.ScanIndexForward,
+	}
+	var expressionContext = newExpressionContext(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	var entries []item
+	var result readResult
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, err = f.table(params.TableName); nil != err {
+		return
+	}
+	if x, err = t.index(params.IndexName); nil != err {
+		return
+	}
+	if aws.ToBool(params.ConsistentRead) && nil != x && !x.local {
+		err = validationError("Consistent reads are not supported on global secondary indexes")
+		return
+	}
+	if err = checkSelect(x, params.Select, params.ProjectionExpression); nil != err {
+		return
+	}
+
+	if nil == params.KeyConditionExpression {
+		err = validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request")
+		return
+	}
+	if keyCondition, err = parseCondition(*params.KeyConditionExpression, expressionContext); nil != err {
+		return
+	}
+	if err = checkKeyCondition(keyCondition, t.schema(x)); nil != err {
+		return
+	}
+	if request.filter, err = compileCondition(expressionContext, params.FilterExpression); nil != err {
+		return
+	}
+	if request.projection, err = compileProjection(expressionContext, params.ProjectionExpression); nil != err {
+		return
+	}
+	if err = expressionContext.checkUnused(); nil != err {
+		return
+	}
+
+	for _, entry := range t.entries(x) {
+		var match bool
+
+		if match, err = keyCondition.eval(entry); nil != err {
+			return
+		}
+		if match {
+			entries = append(entries, entry)
+		}
+	}
+
+	if result, err = t.read(x, entries, request); nil != err {
+		return
+	}
+
+	output = &dynamodb.QueryOutput{
+		Items:            result.items,
+		Count:            result.count,
+		ScannedCount:     result.scannedCount,
+		LastEvaluatedKey: result.lastEvaluatedKey,
+		ConsumedCapacity: readCapacity(t, x, result.size, aws.ToBool(params.ConsistentRead), params.ReturnConsumedCapacity),
+	}
+	if !request.selectCount && nil == output.Items {
+		output.Items = []map[string]types.AttributeValue{}
+	}
+
+	return
+}
+
+// Scan supports parallel scans, items being assigned to a segment by the hash
+// of their hash key.
+func (f *Fake) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.ScanOutput, err error) {
+	var t *table
+	var x *index
+	var request = readRequest{
+		exclusiveStartKey: params.ExclusiveStartKey,
+		limit:             params.Limit,
+		selectCount:       types.SelectCount == params.Select,
+		forward:           true,
+	}
+	var expressionContext = newExpressionContext(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	var entries []item
+	var result readResult
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, err = f.table(params.TableName); nil != err {
+		return
+	}
+	if x, err = t.index(params.IndexName); nil != err {
+		return
+	}
+	if aws.ToBool(params.ConsistentRead) && nil != x && !x.local {
+		err = validationError("Consistent reads are not supported on global secondary indexes")
+		return
+	}
+	if err = checkSelect(x, params.Select, params.ProjectionExpression); nil != err {
+		return
+	}
+	if request.filter, err = compileCondition(expressionContext, params.FilterExpression); nil != err {
+		return
+	}
+	if request.projection, err = compileProjection(expressionContext, params.ProjectionExpression); nil != err {
+		return
+	}
+	if err = expressionContext.checkUnused(); nil != err {
+		return
+	}
+
+	segment, totalSegments := aws.ToInt32(params.Segment), aws.ToInt32(params.TotalSegments)
+	if (nil == params.Segment) != (nil == params.TotalSegments) || (nil != params.TotalSegments && (0 >= totalSegments || 0 > segment || segment >= totalSegments)) {
+		err = validationError("Segment and TotalSegments must be set together, with 0 <= Segment < TotalSegments")
+		return
+	}
+
+	for _, entry := range t.entries(x) {
+		if 0 < totalSegments {
+			h := fnv.New32a()
+			h.Write([]byte(encodeKeyValue(entry[t.schema(x).hashKey])))
+			if int32(h.Sum32()%uint32(totalSegments)) != segment {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if result, err = t.read(x, entries, request); nil != err {
+		return
+	}
+
+	output = &dynamodb.ScanOutput{
+		Items:            result.items,
+		Count:            result.count,
+		ScannedCount:     result.scannedCount,
+		LastEvaluatedKey: result.lastEvaluatedKey,
+		ConsumedCapacity: readCapacity(t, x, result.size, aws.ToBool(params.ConsistentRead), params.ReturnConsumedCapacity),
+	}
+	if !request.selectCount && nil == output.Items {
+		output.Items = []map[string]types.AttributeValue{}
+	}
+
+	return
+}
+
+// BatchGetItem never leaves keys unprocessed.
+func (f *Fake) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.BatchGetItemOutput, err error) {
+	var total int
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	for _, keysAndAttributes := range params.RequestItems {
+		total += len(keysAndAttributes.Keys)
+	}
+	if 0 == total || 100 < total {
+		err = validationError("Too many items requested for the BatchGetItem call: between 1 and 100 keys are required")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	output = &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]types.AttributeValue{},
+		UnprocessedKeys: map[string]types.KeysAndAttributes{},
+	}
+
+	for _, tableName := range sortedKeys(params.RequestItems) {
+		var t *table
+		var paths []documentPath
+		var request = params.RequestItems[tableName]
+		var expressionContext = newExpressionContext(request.ExpressionAttributeNames, nil)
+		var seen = map[string]bool{}
+		var size int
+
+		if t, err = f.table(aws.String(tableName)); nil != err {
+			output = nil
+			return
+		}
+		if paths, err = compileProjection(expressionContext, request.ProjectionExpression); nil != err {
+			output = nil
+			return
+		}
+		if err = expressionContext.checkUnused(); nil != err {
+			output = nil
+			return
+		}
+
+		items := []map[string]types.AttributeValue{}
+		for _, key := range request.Keys {
+			if err = t.checkKey(key); nil != err {
+				output = nil
+				return
+			}
+
+			encoded := t.encodeKey(key)
+			if seen[encoded] {
+				err = validationError("Provided list of item keys contains duplicates")
+				output = nil
+				return
+			}
+			seen[encoded] = true
+
+			if it, ok := t.items[encoded]; ok {
+				size += itemSize(it)
+				items = append(items, project(it, paths))
+			}
+		}
+		output.Responses[tableName] = items
+
+		if consumed := readCapacity(t, nil, size, aws.ToBool(request.ConsistentRead), params.ReturnConsumedCapacity); nil != consumed {
+			output.ConsumedCapacity = append(output.ConsumedCapacity, *consumed)
+		}
+	}
+
+	return
+}
+
+// BatchWriteItem never leaves items unprocessed.
+func (f *Fake) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.BatchWriteItemOutput, err error) {
+	var writes []write
+	var total int
+	var seen = map[string]bool{}
+	var sizes = map[string]int{}
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	for _, requests := range params.RequestItems {
+		total += len(requests)
+	}
+	if 0 == total || 25 < total {
+		err = validationError("Too many items requested for the BatchWriteItem call: between 1 and 25 requests are required")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, tableName := range sortedKeys(params.RequestItems) {
+		for _, request := range params.RequestItems[tableName] {
+			var w write
+
+			switch {
+			case nil != request.PutRequest && nil == request.DeleteRequest:
+				w, err = f.prepare(writeRequest{kind: writePut, tableName: aws.String(tableName), item: request.PutRequest.Item})
+			case nil != request.DeleteRequest && nil == request.PutRequest:
+				w, err = f.prepare(writeRequest{kind: writeDelete, tableName: aws.String(tableName), key: request.DeleteRequest.Key})
+			default:
+				err = validationError("Exactly one of PutRequest or DeleteRequest must be set")
+			}
+			if nil != err {
+				return
+			}
+
+			if seen[tableName+"/"+w.key] {
+				err = validationError("Provided list of item keys contains duplicates")
+				return
+			}
+			seen[tableName+"/"+w.key] = true
+
+			sizes[tableName] += max(1024, w.size())
+			writes = append(writes, w)
+		}
+	}
+
+	for _, w := range writes {
+		w.apply()
+	}
+
+	output = &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]types.WriteRequest{},
+	}
+	for _, tableName := range sortedKeys(sizes) {
+		if consumed := writeCapacity(f.tables[tableName], sizes[tableName], params.ReturnConsumedCapacity); nil != consumed {
+			output.ConsumedCapacity = append(output.ConsumedCapacity, *consumed)
+		}
+	}
+
+	return
+}
+
+// TransactWriteItems applies every action or none. Failed conditions cancel
+// the transaction with a reason per action, in request order.
+func (f *Fake) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.TransactWriteItemsOutput, err error) {
+	var writes []write
+	var reasons []types.CancellationReason
+	var codes []string
+	var canceled bool
+	var seen = map[string]bool{}
+	var sizes = map[string]int{}
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	if 0 == len(params.TransactItems) || 100 < len(params.TransactItems) {
+		err = validationError("Member must have length between 1 and 100: TransactItems")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, transactItem := range params.TransactItems {
+		var request writeRequest
+		var w write
+		var actions int
+
+		if nil != transactItem.ConditionCheck {
+			actions++
+			request = writeRequest{
+				kind:            writeCheck,
+				tableName:       transactItem.ConditionCheck.TableName,
+				key:             transactItem.ConditionCheck.Key,
+				condition:       transactItem.ConditionCheck.ConditionExpression,
+				names:           transactItem.ConditionCheck.ExpressionAttributeNames,
+				values:          transactItem.ConditionCheck.ExpressionAttributeValues,
+				returnOnFailure: transactItem.ConditionCheck.ReturnValuesOnConditionCheckFailure,
+			}
+		}
+		if nil != transactItem.Put {
+			actions++
+			request = writeRequest{
+				kind:            writePut,
+				tableName:       transactItem.Put.TableName,
+				item:            transactItem.Put.Item,
+				condition:       transactItem.Put.ConditionExpression,
+				names:           transactItem.Put.ExpressionAttributeNames,
+				values:          transactItem.Put.ExpressionAttributeValues,
+				returnOnFailure: transactItem.Put.ReturnValuesOnConditionCheckFailure,
+			}
+		}
+		if nil != transactItem.Update {
+			actions++
+			request = writeRequest{
+				kind:            writeUpdate,
+				tableName:       transactItem.Update.TableName,
+				key:             transactItem.Update.Key,
+				update:          transactItem.Update.UpdateExpression,
+				condition:       transactItem.Update.ConditionExpression,
+				names:           transactItem.Update.ExpressionAttributeNames,
+				values:          transactItem.Update.ExpressionAttributeValues,
+				returnOnFailure: transactItem.Update.ReturnValuesOnConditionCheckFailure,
+			}
+		}
+		if nil != transactItem.Delete {
+			actions++
+			request = writeRequest{
+				kind:            writeDelete,
+				tableName:       transactItem.Delete.TableName,
+				key:             transactItem.Delete.Key,
+				condition:       transactItem.Delete.ConditionExpression,
+				names:           transactItem.Delete.ExpressionAttributeNames,
+				values:          transactItem.Delete.ExpressionAttributeValues,
+				returnOnFailure: transactItem.Delete.ReturnValuesOnConditionCheckFailure,
+			}
+		}
+		if 1 != actions {
+			err = validationError("Exactly one of ConditionCheck, Put, Update or Delete must be set on a TransactWriteItem")
+			return
+		}
+
+		w, err = f.prepare(request)
+
+		var conditionalCheckFailed *types.ConditionalCheckFailedException
+		switch {
+		case nil == err:
+			reasons = append(reasons, types.CancellationReason{Code: aws.String("None")})
+			codes = append(codes, "None")
+		case errors.As(err, &conditionalCheckFailed):
+			canceled = true
+			reasons = append(reasons, types.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: conditionalCheckFailed.Message,
+				Item:    conditionalCheckFailed.Item,
+			})
+			codes = append(codes, "ConditionalCheckFailed")
+			err = nil
+		default:
+			return
+		}
+
+		itemKey := aws.ToString(request.tableName) + "/" + w.key
+		if seen[itemKey] {
+			err = validationError("Transaction request cannot include multiple operations on one item")
+			return
+		}
+		seen[itemKey] = true
+
+		if nil != w.table {
+			sizes[aws.ToString(request.tableName)] += 2 * max(1024, w.size())
+		}
+		writes = append(writes, w)
+	}
+
+	if canceled {
+		err = &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+			CancellationReasons: reasons,
+		}
+		return
+	}
+
+	for _, w := range writes {
+		w.apply()
+	}
+
+	output = &dynamodb.TransactWriteItemsOutput{}
+	for _, tableName := range sortedKeys(sizes) {
+		if consumed := writeCapacity(f.tables[tableName], sizes[tableName], params.ReturnConsumedCapacity); nil != consumed {
+			output.ConsumedCapacity = append(output.ConsumedCapacity, *consumed)
+		}
+	}
+
+	return
+}
+
+func (f *Fake) TransactGetItems(ctx context.Context, params *dynamodb.TransactGetItemsInput, optFns ...func(*dynamodb.Options)) (output *dynamodb.TransactGetItemsOutput, err error) {
+	var sizes = map[string]int{}
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	if 0 == len(params.TransactItems) || 100 < len(params.TransactItems) {
+		err = validationError("Member must have length between 1 and 100: TransactItems")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	output = &dynamodb.TransactGetItemsOutput{}
+
+	for _, transactItem := range params.TransactItems {
+		var t *table
+		var paths []documentPath
+		var response types.ItemResponse
+
+		if nil == transactItem.Get {
+			err = validationError("Get must be set on a TransactGetItem")
+			output = nil
+			return
+		}
+
+		get := transactItem.Get
+		expressionContext := newExpressionContext(get.ExpressionAttributeNames, nil)
+
+		if t, err = f.table(get.TableName); nil == err {
+			err = t.checkKey(get.Key)
+		}
+		if nil == err {
+			paths, err = compileProjection(expressionContext, get.ProjectionExpression)
+		}
+		if nil == err {
+			err = expressionContext.checkUnused()
+		}
+		if nil != err {
+			output = nil
+			return
+		}
+
+		if it, ok := t.items[t.encodeKey(get.Key)]; ok {
+			response.Item = project(it, paths)
+			sizes[aws.ToString(get.TableName)] += 2 * max(4096, itemSize(it))
+		} else {
+			sizes[aws.ToString(get.TableName)] += 2 * 4096
+		}
+		output.Responses = append(output.Responses, response)
+	}
+
+	for _, tableName := range sortedKeys(sizes) {
+		if consumed := readCapacity(f.tables[tableName], nil, sizes[tableName], true, params.ReturnConsumedCapacity); nil != consumed {
+			output.ConsumedCapacity = append(output.ConsumedCapacity, *consumed)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,308 @@
+package ddbtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/seill/ddb"
+)
+
+var ErrReplayMismatch = errors.New("replay mismatch")
+
+// Interaction is one recorded call. Request and Response are the SDK input and
+// output in JSON, attribute values in the DynamoDB JSON format.
+type Interaction struct {
+	Operation string          `json:"operation"`
+	Request   json.RawMessage `json:"request"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Error     *RecordedError  `json:"error,omitempty"`
+}
+
+// RecordedError is a recorded error. Errors with a DynamoDB exception code are
+// replayed as that exception, so that retries and errors.As behave as recorded.
+type RecordedError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	// CancellationReasons are the reason codes of a TransactionCanceledException.
+	CancellationReasons []string `json:"cancellationReasons,omitempty"`
+}
+
+// Recorder is a ddb.Client that either records the calls it forwards to a
+// client, or replays recorded calls: each request must then match the next
+// recorded one, and gets the recorded response.
+type Recorder struct {
+	mu           sync.Mutex
+	client       ddb.Client
+	replay       bool
+	interactions []Interaction
+	replayed     int
+}
+
+var _ ddb.Client = (*Recorder)(nil)
+
+// NewRecorder records the calls forwarded to client.
+func NewRecorder(client ddb.Client) *Recorder {
+	return &Recorder{client: client}
+}
+
+// NewReplayer replays interactions in order.
+func NewReplayer(interactions []Interaction) *Recorder {
+	return &Recorder{replay: true, interactions: interactions}
+}
+
+// LoadReplayer replays the interactions saved in path.
+func LoadReplayer(path string) (recorder *Recorder, err error) {
+	var data []byte
+	var interactions []Interaction
+
+	if data, err = os.ReadFile(path); nil != err {
+		return
+	}
+	if err = json.Unmarshal(data, &interactions); nil != err {
+		err = fmt.Errorf("invalid recording (%s): %w", path, err)
+		return
+	}
+
+	recorder = NewReplayer(interactions)
+
+	return
+}
+
+// Golden returns a Recorder bound to the golden file path: with update set,
+// calls are forwarded to client and saved to path when t completes; otherwise
+// they are replayed from path and t fails on a mismatch or on recorded calls
+// left unreplayed.
+func Golden(t testing.TB, path string, client ddb.Client, update bool) (recorder *Recorder) {
+	var err error
+
+	t.Helper()
+
+	if update {
+		recorder = NewRecorder(client)
+		t.Cleanup(func() {
+			if err := recorder.Save(path); nil != err {
+				t.Error(err)
+			}
+		})
+		return
+	}
+
+	if recorder, err = LoadReplayer(path); nil != err {
+		t.Fatalf("%v, record the golden file with update set", err)
+	}
+	t.Cleanup(func() {
+		if err := recorder.Done(); nil != err {
+			t.Error(err)
+		}
+	})
+
+	return
+}
+
+// Interactions returns the interactions recorded, or being replayed.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the interactions to path as indented JSON.
+func (r *Recorder) Save(path string) (err error) {
+	var data []byte
+
+	if data, err = json.MarshalIndent(r.Interactions(), "", "  "); nil != err {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); nil != err {
+		return
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Done reports recorded interactions that were not replayed.
+func (r *Recorder) Done() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.replay && r.replayed < len(r.interactions) {
+		err = fmt.Errorf("%w: %d of %d recorded calls not replayed, next is %s", ErrReplayMismatch, len(r.interactions)-r.replayed, len(r.interactions), r.interactions[r.replayed].Operation)
+	}
+
+	return
+}
+
+func call[I any, O any](r *Recorder, ctx context.Context, operation string, params *I, forward func() (*O, error)) (output *O, err error) {
+	var request json.RawMessage
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	if request, err = marshal(params); nil != err {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.replay {
+		return replay[O](r, operation, request)
+	}
+
+	interaction := Interaction{Operation: operation, Request: request}
+
+	output, err = forward()
+	if nil != err {
+		interaction.Error = &RecordedError{Message: err.Error()}
+
+		var apiError smithy.APIError
+		if errors.As(err, &apiError) {
+			interaction.Error = recordError(apiError)
+		}
+	} else if interaction.Response, err = marshal(output); nil != err {
+		return
+	}
+
+	r.interactions = append(r.interactions, interaction)
+
+	return
+}
+
+func replay[O any](r *Recorder, operation string, request json.RawMessage) (output *O, err error) {
+	var recorded json.RawMessage
+
+	if r.replayed >= len(r.interactions) {
+		err = fmt.Errorf("%w: unexpected call %d %s %s", ErrReplayMismatch, r.replayed+1, operation, request)
+		return
+	}
+
+	interaction := r.interactions[r.replayed]
+
+	if recorded, err = canonical(interaction.Request); nil != err {
+		return
+	}
+	if operation != interaction.Operation || !bytes.Equal(recorded, request) {
+		err = fmt.Errorf("%w: call %d\nrecorded: %s %s\nactual:   %s %s", ErrReplayMismatch, r.replayed+1, interaction.Operation, recorded, operation, request)
+		return
+	}
+
+	r.replayed++
+
+	if nil != interaction.Error {
+		err = interaction.Error.err()
+		return
+	}
+
+	output = new(O)
+	if nil != interaction.Response {
+		err = unmarshal(interaction.Response, output)
+	}
+
+	return
+}
+
+func recordError(apiError smithy.APIError) (recorded *RecordedError) {
+	var transactionCanceled *types.TransactionCanceledException
+
+	recorded = &RecordedError{Code: apiError.ErrorCode(), Message: apiError.ErrorMessage()}
+
+	if errors.As(apiError, &transactionCanceled) {
+		for _, reason := range transactionCanceled.CancellationReasons {
+			recorded.CancellationReasons = append(recorded.CancellationReasons, aws.ToString(reason.Code))
+		}
+	}
+
+	return
+}
+
+// err rebuilds the recorded error, typed after its code when it is a DynamoDB
+// exception.
+func (e *RecordedError) err() error {
+	var message = aws.String(e.Message)
+
+	switch e.Code {
+	case "":
+		return errors.New(e.Message)
+	case "ProvisionedThroughputExceededException":
+		return &types.ProvisionedThroughputExceededException{Message: message}
+	case "RequestLimitExceeded":
+		return &types.RequestLimitExceeded{Message: message}
+	case "InternalServerError":
+		return &types.InternalServerError{Message: message}
+	case "ConditionalCheckFailedException":
+		return &types.ConditionalCheckFailedException{Message: message}
+	case "TransactionConflictException":
+		return &types.TransactionConflictException{Message: message}
+	case "TransactionCanceledException":
+		var reasons []types.CancellationReason
+		for _, code := range e.CancellationReasons {
+			reasons = append(reasons, types.CancellationReason{Code: aws.String(code)})
+		}
+		return &types.TransactionCanceledException{Message: message, CancellationReasons: reasons}
+	case "ItemCollectionSizeLimitExceededException":
+		return &types.ItemCollectionSizeLimitExceededException{Message: message}
+	case "ResourceNotFoundException":
+		return &types.ResourceNotFoundException{Message: message}
+	case "ResourceInUseException":
+		return &types.ResourceInUseException{Message: message}
+	case "LimitExceededException":
+		return &types.LimitExceededException{Message: message}
+	}
+
+	return &smithy.GenericAPIError{Code: e.Code, Message: e.Message}
+}
+
+func (r *Recorder) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return call(r, ctx, "GetItem", params, func() (*dynamodb.GetItemOutput, error) {
+		return r.client.GetItem(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return call(r, ctx, "PutItem", params, func() (*dynamodb.PutItemOutput, error) {
+		return r.client.PutItem(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return call(r, ctx, "UpdateItem", params, func() (*dynamodb.UpdateItemOutput, error) {
+		return r.client.UpdateItem(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return call(r, ctx, "DeleteItem", params, func() (*dynamodb.DeleteItemOutput, error) {
+		return r.client.DeleteItem(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return call(r, ctx, "Query", params, func() (*dynamodb.QueryOutput, error) {
+		return r.client.Query(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return call(r, ctx, "Scan", params, func() (*dynamodb.ScanOutput, error) {
+		return r.client.Scan(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	return call(r, ctx, "BatchGetItem", params, func() (*dynamodb.BatchGetItemOutput, error) {
+		return r.client.BatchGetItem(ctx, params, optFns...)
+	})
+}
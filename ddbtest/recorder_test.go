@@ -0,0 +1,114 @@
+package ddbtest_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/seill/ddb"
+	"github.com/seill/ddb/ddbtest"
+)
+
+var update = flag.Bool("update", false, "record the golden files")
+
+func runGoldenCalls(t *testing.T, r *ddb.Ddb) {
+	user := User{Name: "Alice", Group: "admin", Score: 10}
+	user.PK = "USER#Alice"
+	user.SK = "PROFILE"
+	user.GSI1PK = aws.String("USER")
+	user.GSI1SK = aws.String("Alice")
+	if err := r.CreateItem(user); nil != err {
+		t.Fatal(err)
+	}
+
+	_, err := r.UpdateItem(ddb.Key{PK: aws.String("USER#Alice"), SK: aws.String("PROFILE")}, map[string]interface{}{
+		"Name":              "Alice B.",
+		"Group":             "staff",
+		"Fn:increase:Score": 5,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	items, _, err := r.GetListItem(ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)}, "Name,Score", ddb.QueryOption{
+		Filter: map[string]interface{}{"name": map[string]interface{}{"field": "Name", "type": "begins_with", "keyword": "Alice"}},
+		Where:  goldenWhere(),
+	})
+	if nil != err || 1 != len(items) || "15" != items[0]["Score"].(*types.AttributeValueMemberN).Value {
+		t.Errorf("unexpected items: %v %v", items, err)
+	}
+}
+
+func goldenWhere() *ddb.FilterExpression {
+	where := ddb.Or(ddb.Equal("Group", "staff"), ddb.In("Score", 1, 2))
+
+	return &where
+}
+
+func TestGolden(t *testing.T) {
+	fixed := func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	recorder := ddbtest.Golden(t, "testdata/ddb.golden.json", ddbtest.NewFakeWithTable("table"), *update)
+
+	runGoldenCalls(t, ddb.New(recorder, "table").SetClock(fixed))
+}
+
+// throttledClient fails the first Query with a throttling exception.
+type throttledClient struct {
+	ddb.Client
+	throttled bool
+}
+
+func (c *throttledClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if !c.throttled {
+		c.throttled = true
+		return nil, &types.ProvisionedThroughputExceededException{Message: aws.String("Rate of requests exceeds the allowed throughput.")}
+	}
+
+	return c.Client.Query(ctx, params, optFns...)
+}
+
+func TestGoldenRetry(t *testing.T) {
+	var throughputExceeded *types.ProvisionedThroughputExceededException
+
+	fixed := func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	recorder := ddbtest.Golden(t, "testdata/retry.golden.json", &throttledClient{Client: ddbtest.NewFakeWithTable("table")}, *update)
+	r := ddb.New(recorder, "table").SetClock(fixed)
+
+	runGoldenCalls(t, r.SetRetryPolicy(ddb.RetryPolicy{MaxAttempts: 2}))
+
+	interactions := recorder.Interactions()
+	if 4 != len(interactions) || nil == interactions[2].Error || "ProvisionedThroughputExceededException" != interactions[2].Error.Code {
+		t.Fatalf("expected a throttled Query to be retried: %+v", interactions)
+	}
+
+	// replayed without a retry policy, the throttle surfaces as recorded
+	_, _, err := ddb.New(ddbtest.NewReplayer(interactions[2:3]), "table").SetClock(fixed).GetListItem(ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)}, "Name,Score", ddb.QueryOption{
+		Filter: map[string]interface{}{"name": map[string]interface{}{"field": "Name", "type": "begins_with", "keyword": "Alice"}},
+		Where:  goldenWhere(),
+	})
+	if !errors.As(err, &throughputExceeded) {
+		t.Errorf("expected ProvisionedThroughputExceededException, got %T %v", err, err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	recorder := ddbtest.NewRecorder(ddbtest.NewFakeWithTable("table"))
+	runGoldenCalls(t, ddb.New(recorder, "table"))
+
+	replayer := ddbtest.NewReplayer(recorder.Interactions())
+	r := ddb.New(replayer, "table")
+	user := User{Name: "Bob"}
+	user.PK = "USER#Bob"
+	user.SK = "PROFILE"
+	if err := r.CreateItem(user); !errors.Is(err, ddbtest.ErrReplayMismatch) {
+		t.Errorf("expected ErrReplayMismatch, got %v", err)
+	}
+	if err := replayer.Done(); !errors.Is(err, ddbtest.ErrReplayMismatch) {
+		t.Errorf("expected unreplayed calls, got %v", err)
+	}
+}
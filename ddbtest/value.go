@@ -0,0 +1,312 @@
+package ddbtest
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type item = map[string]types.AttributeValue
+
+func parseNumber(s string) (number *big.Rat, err error) {
+	var ok bool
+
+	number, ok = new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		err = validationError("invalid number (%s)", s)
+	}
+
+	return
+}
+
+func formatNumber(number *big.Rat) string {
+	if number.IsInt() {
+		return number.Num().String()
+	}
+
+	return strings.TrimRight(strings.TrimRight(number.FloatString(38), "0"), ".")
+}
+
+func normalizeNumber(s string) string {
+	number, err := parseNumber(s)
+	if nil != err {
+		return s
+	}
+
+	return formatNumber(number)
+}
+
+// typeOf returns the DynamoDB type descriptor of av (S, N, B, SS, NS, BS, BOOL, NULL, L, M).
+func typeOf(av types.AttributeValue) string {
+	switch av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	}
+
+	return ""
+}
+
+// compareScalar orders two S, N or B values of the same type.
+func compareScalar(a types.AttributeValue, b types.AttributeValue) (c int, ok bool) {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS:
+		if bv, isS := b.(*types.AttributeValueMemberS); isS {
+			return strings.Compare(av.Value, bv.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if bv, isN := b.(*types.AttributeValueMemberN); isN {
+			x, errA := parseNumber(av.Value)
+			y, errB := parseNumber(bv.Value)
+			if nil == errA && nil == errB {
+				return x.Cmp(y), true
+			}
+		}
+	case *types.AttributeValueMemberB:
+		if bv, isB := b.(*types.AttributeValueMemberB); isB {
+			return bytes.Compare(av.Value, bv.Value), true
+		}
+	}
+
+	return 0, false
+}
+
+func equal(a types.AttributeValue, b types.AttributeValue) bool {
+	if nil == a || nil == b {
+		return false
+	}
+	if typeOf(a) != typeOf(b) {
+		return false
+	}
+
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS, *types.AttributeValueMemberN, *types.AttributeValueMemberB:
+		c, ok := compareScalar(a, b)
+		return ok && 0 == c
+	case *types.AttributeValueMemberBOOL:
+		return av.Value == b.(*types.AttributeValueMemberBOOL).Value
+	case *types.AttributeValueMemberNULL:
+		return true
+	case *types.AttributeValueMemberSS:
+		return sameSet(av.Value, b.(*types.AttributeValueMemberSS).Value, func(s string) string { return s })
+	case *types.AttributeValueMemberNS:
+		return sameSet(av.Value, b.(*types.AttributeValueMemberNS).Value, normalizeNumber)
+	case *types.AttributeValueMemberBS:
+		x, y := b.(*types.AttributeValueMemberBS).Value, av.Value
+		return sameSet(bytesToStrings(x), bytesToStrings(y), func(s string) string { return s })
+	case *types.AttributeValueMemberL:
+		bv := b.(*types.AttributeValueMemberL).Value
+		if len(av.Value) != len(bv) {
+			return false
+		}
+		for i := range av.Value {
+			if !equal(av.Value[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberM:
+		bv := b.(*types.AttributeValueMemberM).Value
+		if len(av.Value) != len(bv) {
+			return false
+		}
+		for k, v := range av.Value {
+			if !equal(v, bv[k]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+func bytesToStrings(values [][]byte) (strs []string) {
+	for _, v := range values {
+		strs = append(strs, string(v))
+	}
+
+	return
+}
+
+func sameSet(a []string, b []string, normalize func(string) string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	members := map[string]bool{}
+	for _, v := range a {
+		members[normalize(v)] = true
+	}
+	for _, v := range b {
+		if !members[normalize(v)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func size(av types.AttributeValue) (n int, ok bool) {
+	ok = true
+
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		n = utf8.RuneCountInString(v.Value)
+	case *types.AttributeValueMemberB:
+		n = len(v.Value)
+	case *types.AttributeValueMemberSS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberNS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberBS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberL:
+		n = len(v.Value)
+	case *types.AttributeValueMemberM:
+		n = len(v.Value)
+	default:
+		ok = false
+	}
+
+	return
+}
+
+func copyValue(av types.AttributeValue) types.AttributeValue {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte(nil), v.Value...)}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberBS:
+		values := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			values[i] = append([]byte(nil), b...)
+		}
+		return &types.AttributeValueMemberBS{Value: values}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberL:
+		values := make([]types.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			values[i] = copyValue(e)
+		}
+		return &types.AttributeValueMemberL{Value: values}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(v.Value)}
+	}
+
+	return av
+}
+
+func copyItem(source item) item {
+	if nil == source {
+		return nil
+	}
+
+	target := make(item, len(source))
+	for k, v := range source {
+		target[k] = copyValue(v)
+	}
+
+	return target
+}
+
+// encodeKeyValue renders a key attribute so that equal keys encode equally.
+func encodeKeyValue(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S:" + v.Value
+	case *types.AttributeValueMemberN:
+		return "N:" + normalizeNumber(v.Value)
+	case *types.AttributeValueMemberB:
+		return fmt.Sprintf("B:%x", v.Value)
+	}
+
+	return ""
+}
+
+// itemSize approximates the size of an item in bytes.
+func itemSize(it item) (n int) {
+	for k, v := range it {
+		n += len(k) + valueSize(v)
+	}
+
+	return
+}
+
+func valueSize(av types.AttributeValue) (n int) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberN:
+		n = len(v.Value)/2 + 1
+	case *types.AttributeValueMemberB:
+		n = len(v.Value)
+	case *types.AttributeValueMemberSS:
+		for _, s := range v.Value {
+			n += len(s)
+		}
+	case *types.AttributeValueMemberNS:
+		for _, s := range v.Value {
+			n += len(s)/2 + 1
+		}
+	case *types.AttributeValueMemberBS:
+		for _, b := range v.Value {
+			n += len(b)
+		}
+	case *types.AttributeValueMemberL:
+		n = 3
+		for _, e := range v.Value {
+			n += 1 + valueSize(e)
+		}
+	case *types.AttributeValueMemberM:
+		n = 3
+		for k, e := range v.Value {
+			n += 1 + len(k) + valueSize(e)
+		}
+	default:
+		n = 1
+	}
+
+	return
+}
+
+func sortedKeys[V any](m map[string]V) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return
+}
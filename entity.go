@@ -0,0 +1,204 @@
+package ddb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/seill/util"
+)
+
+const DefaultEntityTypeAttribute = "EntityType"
+
+var ErrUnknownEntityType = errors.New("unknown entity type")
+
+var ErrEntityRegistryRequired = errors.New("entity registry required")
+
+var recordType = reflect.TypeOf((*IDynamoDbRecord)(nil)).Elem()
+
+// EntityRegistry names the record types stored in a single table. CreateItem
+// writes the name of a registered type to the discriminator attribute, and
+// Decode uses it to unmarshal an item into a new record of that type.
+type EntityRegistry struct {
+	attribute string
+	types     map[string]reflect.Type
+	names     map[reflect.Type]string
+}
+
+func NewEntityRegistry() *EntityRegistry {
+	return &EntityRegistry{
+		attribute: DefaultEntityTypeAttribute,
+		types:     map[string]reflect.Type{},
+		names:     map[reflect.Type]string{},
+	}
+}
+
+// SetAttribute replaces DefaultEntityTypeAttribute as the discriminator attribute.
+func (e *EntityRegistry) SetAttribute(attribute string) *EntityRegistry {
+	e.attribute = attribute
+
+	return e
+}
+
+func (e *EntityRegistry) Attribute() string {
+	return e.attribute
+}
+
+// Register names the type of record, a struct or a pointer to one. Records
+// decoded as name are pointers to that struct. Decode never calls BuildPk, so
+// the type may rely on the one promoted from DynamoDbMetaData.
+func (e *EntityRegistry) Register(name string, record IDynamoDbRecord) (err error) {
+	var t = reflect.TypeOf(record)
+
+	if nil != t && reflect.Pointer == t.Kind() {
+		t = t.Elem()
+	}
+
+	switch {
+	case "" == name:
+		err = errors.New("invalid entity type: name is required")
+	case nil == t || reflect.Struct != t.Kind() || !reflect.PointerTo(t).Implements(recordType):
+		err = fmt.Errorf("invalid entity type: %s must be a struct implementing IDynamoDbRecord (%v)", name, t)
+	case nil != e.types[name]:
+		err = fmt.Errorf("invalid entity type: %s is already registered as %v", name, e.types[name])
+	case "" != e.names[t]:
+		err = fmt.Errorf("invalid entity type: %v is already registered as %s", t, e.names[t])
+	default:
+		e.types[name] = t
+		e.names[t] = name
+	}
+
+	return
+}
+
+// TypeName returns the name record is registered as.
+func (e *EntityRegistry) TypeName(record interface{}) (name string, ok bool) {
+	var t = reflect.TypeOf(record)
+
+	if nil != t && reflect.Pointer == t.Kind() {
+		t = t.Elem()
+	}
+	name, ok = e.names[t]
+
+	return
+}
+
+// Decode unmarshals item into a new record of the type named by its
+// discriminator attribute.
+func (e *EntityRegistry) Decode(item map[string]types.AttributeValue) (record IDynamoDbRecord, err error) {
+	var name string
+	var t reflect.Type
+
+	if av, ok := item[e.attribute].(*types.AttributeValueMemberS); ok {
+		name = av.Value
+		t = e.types[name]
+	}
+	if nil == t {
+		err = fmt.Errorf("%w (%s=%q, %s)", ErrUnknownEntityType, e.attribute, name, util.StructToString(baseKeyOf(item)))
+		return
+	}
+
+	v := reflect.New(t)
+	if err = attributevalue.UnmarshalMap(item, v.Interface()); nil != err {
+		return
+	}
+
+	record = v.Interface().(IDynamoDbRecord)
+
+	return
+}
+
+// DecodeAll decodes items in order, failing on the first unknown type.
+func (e *EntityRegistry) DecodeAll(items []map[string]types.AttributeValue) (records []IDynamoDbRecord, err error) {
+	records = make([]IDynamoDbRecord, 0, len(items))
+
+	for _, item := range items {
+		var record IDynamoDbRecord
+
+		if record, err = e.Decode(item); nil != err {
+			return
+		}
+		records = append(records, record)
+	}
+
+	return
+}
+
+// SetEntityRegistry makes CreateItem write the discriminator of registered
+// types and enables GetEntity and GetListEntity.
+func (r *Ddb) SetEntityRegistry(registry *EntityRegistry) *Ddb {
+	r.entityRegistry = registry
+
+	return r
+}
+
+// tagEntity writes the discriminator of item to avItem when its type is registered.
+func (r *Ddb) tagEntity(item interface{}, avItem map[string]types.AttributeValue) {
+	if nil == r.entityRegistry {
+		return
+	}
+
+	if name, ok := r.entityRegistry.TypeName(item); ok {
+		avItem[r.entityRegistry.attribute] = &types.AttributeValueMemberS{Value: name}
+	}
+}
+
+// entityProjection adds the discriminator to projection.
+func (r *Ddb) entityProjection(projection []string) []string {
+	if 0 == len(projection) || slices.Contains(projection, r.entityRegistry.attribute) {
+		return projection
+	}
+
+	return append(slices.Clone(projection), r.entityRegistry.attribute)
+}
+
+// GetEntity reads an item like GetItem and decodes it with the entity registry.
+func (r *Ddb) GetEntity(key Key, getOption ...GetOption) (record IDynamoDbRecord, err error) {
+	r, end := r.begin("GetEntity")
+	defer func() { end(err) }()
+
+	var item map[string]types.AttributeValue
+	var option = mergeGetOption(getOption)
+
+	if nil == r.entityRegistry {
+		err = ErrEntityRegistryRequired
+		return
+	}
+
+	option.Projection = r.entityProjection(option.Projection)
+
+	if item, err = r.GetItem(key, option); nil != err {
+		return
+	}
+
+	record, err = r.entityRegistry.Decode(item)
+
+	return
+}
+
+// GetListEntity queries items like GetListItem and decodes each of them with
+// the entity registry, typically over a partition holding several entity types.
+func (r *Ddb) GetListEntity(key Key, queryOption QueryOption) (records []IDynamoDbRecord, lastEvaluatedKey interface{}, err error) {
+	r, end := r.begin("GetListEntity")
+	defer func() { end(err) }()
+
+	var items []map[string]types.AttributeValue
+
+	if nil == r.entityRegistry {
+		err = ErrEntityRegistryRequired
+		return
+	}
+
+	queryOption.Projection = r.entityProjection(queryOption.Projection)
+
+	if items, lastEvaluatedKey, err = r.GetListItem(key, "", queryOption); nil != err {
+		return
+	}
+
+	records, err = r.entityRegistry.DecodeAll(items)
+
+	return
+}
@@ -0,0 +1,54 @@
+package ddb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type entityUser struct {
+	DynamoDbMetaData
+	Name string
+}
+
+// entityInvoice builds its PK from the first letter of the id, which is never
+// called by the registry.
+type entityInvoice struct {
+	DynamoDbMetaData
+}
+
+func (o entityInvoice) BuildPk(id string) string {
+	return "INVOICE#" + id[:1] + "#" + id
+}
+
+func TestEntityRegistry(t *testing.T) {
+	registry := NewEntityRegistry().SetAttribute("Kind")
+
+	if err := registry.Register("User", &entityUser{}); nil != err {
+		t.Fatal(err)
+	}
+	if err := registry.Register("Invoice", entityInvoice{}); nil != err {
+		t.Fatal(err)
+	}
+	for name, record := range map[string]IDynamoDbRecord{"": entityUser{}, "User": &DynamoDbMetaData{}, "Other": entityUser{}} {
+		if err := registry.Register(name, record); nil == err {
+			t.Errorf("expected registering %q as %T to fail", name, record)
+		}
+	}
+
+	put, err := New(nil, "table").SetEntityRegistry(registry).buildPutItemInput(entityUser{Name: "Alice"}, WriteOption{})
+	if nil != err || "User" != put.Item["Kind"].(*types.AttributeValueMemberS).Value {
+		t.Fatalf("unexpected item: %v %v", put.Item, err)
+	}
+
+	record, err := registry.Decode(put.Item)
+	if user, ok := record.(*entityUser); nil != err || !ok || "Alice" != user.Name {
+		t.Errorf("unexpected record: %#v %v", record, err)
+	}
+
+	put.Item["Kind"] = &types.AttributeValueMemberS{Value: "Order"}
+	if _, err = registry.DecodeAll([]map[string]types.AttributeValue{put.Item}); !errors.Is(err, ErrUnknownEntityType) {
+		t.Errorf("expected ErrUnknownEntityType, got %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package ddb
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Explanation is the request a Ddb method would send, built without calling
+// DynamoDB. Input is the SDK input (*dynamodb.QueryInput, *dynamodb.UpdateItemInput, ...)
+// and Notes describe what the method does beyond that request.
+type Explanation struct {
+	Operation string
+	Input     interface{}
+	Notes     []string
+}
+
+func newExplanation(input interface{}, notes ...string) Explanation {
+	return Explanation{
+		Operation: operationName(input),
+		Input:     input,
+		Notes:     notes,
+	}
+}
+
+// ExplainGetItem explains GetItem. On an index, the query is followed by a
+// base table GetItem when FetchFromBase is set.
+func (r *Ddb) ExplainGetItem(key Key, getOption ...GetOption) (explanation Explanation, err error) {
+	var option = mergeGetOption(getOption)
+
+	if nil == key.IndexName {
+		var input *dynamodb.GetItemInput
+
+		input, err = r.buildGetItemInput(key, option)
+		explanation = newExplanation(input)
+		return
+	}
+
+	var input *dynamodb.QueryInput
+	var notes = []string{"pages are read until an item is found"}
+
+	if option.FetchFromBase {
+		notes = append(notes, "the item is then read from the base table by PK/SK")
+		option = GetOption{Projection: baseKeyProjection, ConsistentRead: option.ConsistentRead, IncludeDeleted: option.IncludeDeleted}
+	}
+
+	input, err = r.buildGetItemViaGsiInput(key, option)
+	explanation = newExplanation(input, notes...)
+
+	return
+}
+
+func (r *Ddb) ExplainGetListItem(key Key, arrayOfField string, queryOption QueryOption) (explanation Explanation, err error) {
+	var query listQuery
+	var notes []string
+
+	query, err = r.buildListQuery(key, arrayOfField, queryOption)
+	if err != nil {
+		return
+	}
+
+	if query.inMemorySort {
+		var pageSize = query.input.Limit
+
+		_, err = prepareSortQuery(query.input, query.orders)
+		if err != nil {
+			return
+		}
+		notes = append(notes, fmt.Sprintf("every page is read and sorted in memory by %s", describeOrder(query.orders)))
+		if nil != pageSize {
+			notes = append(notes, fmt.Sprintf("the first %d sorted items are returned", *pageSize))
+		}
+	}
+	if query.fetchFromBase {
+		notes = append(notes, "items are then read from the base table by PK/SK")
+	}
+
+	explanation = newExplanation(query.input, notes...)
+
+	return
+}
+
+func (r *Ddb) ExplainScan(indexName *string, arrayOfField string, queryOption QueryOption) (explanation Explanation, err error) {
+	var input *dynamodb.ScanInput
+
+	input, err = r.buildScanInput(indexName, arrayOfField, queryOption)
+	explanation = newExplanation(input)
+
+	return
+}
+
+func (r *Ddb) ExplainCount(key Key, queryOption QueryOption) (explanation Explanation, err error) {
+	var input *dynamodb.QueryInput
+
+	input, err = r.buildCountInput(key, queryOption)
+	explanation = newExplanation(input, "pages are read until LastEvaluatedKey is empty")
+
+	return
+}
+
+func (r *Ddb) ExplainCreateItem(item interface{}, writeOption ...WriteOption) (explanation Explanation, err error) {
+	var input *dynamodb.PutItemInput
+
+	input, err = r.buildPutItemInput(item, mergeWriteOption(writeOption))
+	explanation = newExplanation(input)
+
+	return
+}
+
+// ExplainUpdateItem explains UpdateItem, leaving propertyMap untouched.
+func (r *Ddb) ExplainUpdateItem(key Key, propertyMap map[string]interface{}, writeOption ...WriteOption) (explanation Explanation, err error) {
+	var input *dynamodb.UpdateItemInput
+
+	input, err = r.buildUpdateItemInput(key, maps.Clone(propertyMap), mergeWriteOption(writeOption))
+	explanation = newExplanation(input)
+
+	return
+}
+
+// ExplainDeleteItem explains DeleteItem. In soft delete mode, the GSI keys to
+// move are only known once the item is read, so none are moved here.
+func (r *Ddb) ExplainDeleteItem(key Key) (explanation Explanation, err error) {
+	if nil != r.softDelete {
+		var input *dynamodb.UpdateItemInput
+		var notes []string
+
+		if r.softDelete.MoveIndexKeys {
+			notes = append(notes, "the item is first read and its GSI keys moved to Deleted<key>")
+		}
+
+		input, err = r.buildSoftDeleteInput(key, nil)
+		explanation = newExplanation(input, notes...)
+		return
+	}
+
+	var input *dynamodb.DeleteItemInput
+
+	input, err = r.buildDeleteItemInput(key)
+	explanation = newExplanation(input)
+
+	return
+}
+
+// explainedInput holds the fields of an SDK input worth rendering.
+type explainedInput struct {
+	tableName         *string
+	indexName         *string
+	expressions       [][2]string
+	names             map[string]string
+	values            map[string]types.AttributeValue
+	key               map[string]types.AttributeValue
+	item              map[string]types.AttributeValue
+	exclusiveStartKey map[string]types.AttributeValue
+	limit             *int32
+	scanIndexForward  *bool
+	consistentRead    *bool
+	selectValue       string
+	returnValues      string
+}
+
+func (e *explainedInput) expression(name string, expression *string) {
+	if nil != expression {
+		e.expressions = append(e.expressions, [2]string{name, *expression})
+	}
+}
+
+func explainInput(input interface{}) (e explainedInput) {
+	switch in := input.(type) {
+	case *dynamodb.GetItemInput:
+		e.tableName, e.key, e.consistentRead, e.names = in.TableName, in.Key, in.ConsistentRead, in.ExpressionAttributeNames
+		e.expression("ProjectionExpression", in.ProjectionExpression)
+	case *dynamodb.QueryInput:
+		e.tableName, e.indexName, e.names, e.values = in.TableName, in.IndexName, in.ExpressionAttributeNames, in.ExpressionAttributeValues
+		e.exclusiveStartKey, e.limit, e.scanIndexForward, e.consistentRead, e.selectValue = in.ExclusiveStartKey, in.Limit, in.ScanIndexForward, in.ConsistentRead, string(in.Select)
+		e.expression("KeyConditionExpression", in.KeyConditionExpression)
+		e.expression("FilterExpression", in.FilterExpression)
+		e.expression("ProjectionExpression", in.ProjectionExpression)
+	case *dynamodb.ScanInput:
+		e.tableName, e.indexName, e.names, e.values = in.TableName, in.IndexName, in.ExpressionAttributeNames, in.ExpressionAttributeValues
+		e.exclusiveStartKey, e.limit, e.consistentRead, e.selectValue = in.ExclusiveStartKey, in.Limit, in.ConsistentRead, string(in.Select)
+		e.expression("FilterExpression", in.FilterExpression)
+		e.expression("ProjectionExpression", in.ProjectionExpression)
+	case *dynamodb.PutItemInput:
+		e.tableName, e.item, e.names, e.values, e.returnValues = in.TableName, in.Item, in.ExpressionAttributeNames, in.ExpressionAttributeValues, string(in.ReturnValues)
+		e.expression("ConditionExpression", in.ConditionExpression)
+	case *dynamodb.UpdateItemInput:
+		e.tableName, e.key, e.names, e.values, e.returnValues = in.TableName, in.Key, in.ExpressionAttributeNames, in.ExpressionAttributeValues, string(in.ReturnValues)
+		e.expression("UpdateExpression", in.UpdateExpression)
+		e.expression("ConditionExpression", in.ConditionExpression)
+	case *dynamodb.DeleteItemInput:
+		e.tableName, e.key, e.names, e.values, e.returnValues = in.TableName, in.Key, in.ExpressionAttributeNames, in.ExpressionAttributeValues, string(in.ReturnValues)
+		e.expression("ConditionExpression", in.ConditionExpression)
+	}
+
+	return
+}
+
+var rePlaceholder = regexp.MustCompile(`[#:][A-Za-z0-9_]+`)
+
+// resolve substitutes the names and values of placeholders in expression.
+func (e explainedInput) resolve(expression string) string {
+	return rePlaceholder.ReplaceAllStringFunc(expression, func(placeholder string) string {
+		if name, ok := e.names[placeholder]; ok {
+			return name
+		}
+		if value, ok := e.values[placeholder]; ok {
+			return formatAttributeValue(value)
+		}
+		return placeholder
+	})
+}
+
+// String renders the request, each expression followed by its resolved form.
+func (e Explanation) String() string {
+	var b strings.Builder
+	var in = explainInput(e.Input)
+
+	b.WriteString(e.Operation)
+	if nil != in.tableName {
+		fmt.Fprintf(&b, " on %s", *in.tableName)
+	}
+	if nil != in.indexName {
+		fmt.Fprintf(&b, ", index %s", *in.indexName)
+	}
+	b.WriteString("\n")
+
+	for _, expression := range in.expressions {
+		fmt.Fprintf(&b, "  %s: %s\n", expression[0], expression[1])
+		fmt.Fprintf(&b, "    = %s\n", in.resolve(expression[1]))
+	}
+
+	if 0 < len(in.names) {
+		var names []string
+
+		for _, placeholder := range sortedMapKeys(in.names) {
+			names = append(names, placeholder+"="+in.names[placeholder])
+		}
+		fmt.Fprintf(&b, "  ExpressionAttributeNames: %s\n", strings.Join(names, ", "))
+	}
+	if 0 < len(in.values) {
+		fmt.Fprintf(&b, "  ExpressionAttributeValues: %s\n", formatAttributeMap(in.values, "="))
+	}
+	if nil != in.key {
+		fmt.Fprintf(&b, "  Key: %s\n", formatAttributeMap(in.key, "="))
+	}
+	if nil != in.item {
+		fmt.Fprintf(&b, "  Item: %s\n", formatAttributeMap(in.item, "="))
+	}
+	if nil != in.exclusiveStartKey {
+		fmt.Fprintf(&b, "  ExclusiveStartKey: %s\n", formatAttributeMap(in.exclusiveStartKey, "="))
+	}
+	if nil != in.limit {
+		fmt.Fprintf(&b, "  Limit: %d\n", *in.limit)
+	}
+	if nil != in.scanIndexForward {
+		fmt.Fprintf(&b, "  ScanIndexForward: %t\n", *in.scanIndexForward)
+	}
+	if nil != in.consistentRead {
+		fmt.Fprintf(&b, "  ConsistentRead: %t\n", *in.consistentRead)
+	}
+	if "" != in.selectValue {
+		fmt.Fprintf(&b, "  Select: %s\n", in.selectValue)
+	}
+	if "" != in.returnValues {
+		fmt.Fprintf(&b, "  ReturnValues: %s\n", in.returnValues)
+	}
+
+	for _, note := range e.Notes {
+		fmt.Fprintf(&b, "  Note: %s\n", note)
+	}
+
+	return b.String()
+}
+
+func sortedMapKeys[V any](m map[string]V) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return
+}
+
+func formatAttributeMap(m map[string]types.AttributeValue, separator string) string {
+	var entries []string
+
+	for _, k := range sortedMapKeys(m) {
+		entries = append(entries, k+separator+formatAttributeValue(m[k]))
+	}
+
+	return strings.Join(entries, ", ")
+}
+
+func formatAttributeValue(av types.AttributeValue) string {
+	var quote = func(values []string) string {
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = strconv.Quote(v)
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return strconv.Quote(v.Value)
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberB:
+		return fmt.Sprintf("<%d bytes>", len(v.Value))
+	case *types.AttributeValueMemberSS:
+		return "SS[" + quote(v.Value) + "]"
+	case *types.AttributeValueMemberNS:
+		return "NS[" + strings.Join(v.Value, ", ") + "]"
+	case *types.AttributeValueMemberBS:
+		return fmt.Sprintf("BS[%d values]", len(v.Value))
+	case *types.AttributeValueMemberBOOL:
+		return strconv.FormatBool(v.Value)
+	case *types.AttributeValueMemberNULL:
+		return "null"
+	case *types.AttributeValueMemberL:
+		values := make([]string, len(v.Value))
+		for i, e := range v.Value {
+			values[i] = formatAttributeValue(e)
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	case *types.AttributeValueMemberM:
+		return "{" + formatAttributeMap(v.Value, ": ") + "}"
+	}
+
+	return fmt.Sprint(av)
+}
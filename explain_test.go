@@ -0,0 +1,49 @@
+package ddb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func TestExplain(t *testing.T) {
+	// a nil client fails the test if anything is sent
+	r := New(nil, "table").SetClock(func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) })
+
+	propertyMap := map[string]interface{}{"Name": "Alice", "Fn:increase:Score": 1}
+	explanation, err := r.ExplainUpdateItem(Key{PK: aws.String("USER#1"), SK: aws.String("PROFILE")}, propertyMap)
+	if nil != err {
+		t.Fatal(err)
+	}
+	input := explanation.Input.(*dynamodb.UpdateItemInput)
+	if "UpdateItem" != explanation.Operation || "set #Name=:Name, #Score=if_not_exists(Score, :_Zero) + :Score, #UpdatedTimestamp=:UpdatedTimestamp" != *input.UpdateExpression {
+		t.Errorf("unexpected update: %s %s", explanation.Operation, *input.UpdateExpression)
+	}
+	if 2 != len(propertyMap) {
+		t.Errorf("expected propertyMap to be left untouched, got %v", propertyMap)
+	}
+	if rendered := explanation.String(); !strings.Contains(rendered, `= set Name="Alice", Score=if_not_exists(Score, 0) + 1, UpdatedTimestamp="2024-01-02T03:04:05Z"`) {
+		t.Errorf("unexpected rendering:\n%s", rendered)
+	}
+
+	where := Equal("Status", "active")
+	explanation, err = r.ExplainGetListItem(Key{PK: aws.String("USER"), IndexName: aws.String(GSI1)}, "Name", QueryOption{
+		Where:        &where,
+		Order:        []QueryOptionOrder{{Field: "Name"}},
+		InMemorySort: true,
+		Page:         &QueryOptionPage{PageSize: 10},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	query := explanation.Input.(*dynamodb.QueryInput)
+	if nil != query.Limit || "#Name" != *query.ProjectionExpression || 2 != len(explanation.Notes) {
+		t.Errorf("unexpected in memory sort query: %s", explanation)
+	}
+	if rendered := explanation.String(); !strings.Contains(rendered, `KeyConditionExpression: #GSI1PK = :gsipk`) || !strings.Contains(rendered, `= Status = "active"`) {
+		t.Errorf("unexpected rendering:\n%s", rendered)
+	}
+}
@@ -0,0 +1,543 @@
+package ddb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// FilterExpression is a node of a filter expression tree. A node is either a
+// group (And, Or), a negation (Not) or a single condition (Field, Type, Keyword).
+type FilterExpression struct {
+	And     []FilterExpression `json:"and,omitempty"`
+	Or      []FilterExpression `json:"or,omitempty"`
+	Not     *FilterExpression  `json:"not,omitempty"`
+	Field   string             `json:"field,omitempty"`
+	Type    string             `json:"type,omitempty"`
+	Keyword interface{}        `json:"keyword,omitempty"`
+	// ValueType optionally forces the DynamoDB type of Keyword (S, N, BOOL, NULL, L, SS, NS).
+	// Without it the type is derived from the Go type of Keyword.
+	ValueType string `json:"valueType,omitempty"`
+}
+
+var reSafeAttributeName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// expressionBuilder allocates attribute name and value placeholders in a
+// deterministic order, sharing the maps of the input being built.
+type expressionBuilder struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	nameCount  int
+	valueCount int
+}
+
+func newExpressionBuilder(names map[string]string, values map[string]types.AttributeValue) *expressionBuilder {
+	return &expressionBuilder{
+		names:  names,
+		values: values,
+	}
+}
+
+func (b *expressionBuilder) name(field string) (placeholder string) {
+	if reSafeAttributeName.MatchString(field) {
+		placeholder = "#" + field
+		if existing, ok := b.names[placeholder]; !ok || existing == field {
+			b.names[placeholder] = field
+			return
+		}
+	}
+
+	for {
+		placeholder = fmt.Sprintf("#_n%d", b.nameCount)
+		b.nameCount++
+		if _, ok := b.names[placeholder]; !ok {
+			break
+		}
+	}
+	b.names[placeholder] = field
+
+	return
+}
+
+var rePathSegment = regexp.MustCompile(`^([^\[\]]+)((?:\[[0-9]+\])*)$`)
+
+// path aliases every map segment of a document path such as "Address.City" or
+// "Orders[0].Items[2]", keeping list indexes as they are.
+func (b *expressionBuilder) path(path string) (expression string, err error) {
+	var segments = strings.Split(path, ".")
+	var expressions = make([]string, len(segments))
+
+	for i, segment := range segments {
+		var match = rePathSegment.FindStringSubmatch(segment)
+
+		if nil == match {
+			err = fmt.Errorf("invalid attribute path (%s)", path)
+			return
+		}
+
+		expressions[i] = b.name(match[1]) + match[2]
+	}
+
+	expression = strings.Join(expressions, ".")
+
+	return
+}
+
+func (b *expressionBuilder) value(v interface{}, valueType string) (placeholder string, err error) {
+	var av types.AttributeValue
+
+	av, err = marshalFilterValue(v, valueType)
+	if nil != err {
+		return
+	}
+
+	for {
+		placeholder = fmt.Sprintf(":_f%d", b.valueCount)
+		b.valueCount++
+		if _, ok := b.values[placeholder]; !ok {
+			break
+		}
+	}
+	b.values[placeholder] = av
+
+	return
+}
+
+func (f FilterExpression) render(b *expressionBuilder) (expression string, err error) {
+	var kinds = 0
+
+	if 0 < len(f.And) {
+		kinds++
+	}
+	if 0 < len(f.Or) {
+		kinds++
+	}
+	if nil != f.Not {
+		kinds++
+	}
+	if "" != f.Field {
+		kinds++
+	}
+	if 1 != kinds {
+		err = fmt.Errorf("filter node must set exactly one of and, or, not, field")
+		return
+	}
+
+	switch {
+	case 0 < len(f.And):
+		expression, err = renderFilterGroup(b, f.And, "AND")
+	case 0 < len(f.Or):
+		expression, err = renderFilterGroup(b, f.Or, "OR")
+	case nil != f.Not:
+		var inner string
+
+		inner, err = f.Not.render(b)
+		if nil != err {
+			return
+		}
+		expression = fmt.Sprintf("NOT (%s)", inner)
+	default:
+		expression, err = f.renderCondition(b)
+	}
+
+	return
+}
+
+func renderFilterGroup(b *expressionBuilder, filters []FilterExpression, operator string) (expression string, err error) {
+	var expressions = make([]string, len(filters))
+
+	for i, filter := range filters {
+		expressions[i], err = filter.render(b)
+		if nil != err {
+			return
+		}
+	}
+
+	if 1 == len(expressions) {
+		expression = expressions[0]
+		return
+	}
+
+	expression = fmt.Sprintf("(%s)", strings.Join(expressions, " "+operator+" "))
+
+	return
+}
+
+var filterComparators = map[string]string{
+	"equal":           "=",
+	"bool":            "=",
+	"not_equal":       "<>",
+	"less_than":       "<",
+	"less_than_equal": "<=",
+	"more_than":       ">",
+	"more_than_equal": ">=",
+}
+
+func (f FilterExpression) renderCondition(b *expressionBuilder) (expression string, err error) {
+	var field string
+	var value string
+	var values []string
+
+	field, err = b.path(f.Field)
+	if nil != err {
+		return
+	}
+
+	switch f.Type {
+	case "exists":
+		expression = fmt.Sprintf("attribute_exists(%s)", field)
+		return
+	case "not_exists":
+		expression = fmt.Sprintf("attribute_not_exists(%s)", field)
+		return
+	case "date", "between":
+		values, err = f.rangeValues(b)
+		if nil != err {
+			return
+		}
+		expression = fmt.Sprintf("%s BETWEEN %s AND %s", field, values[0], values[1])
+		return
+	case "in":
+		values, err = f.listValues(b)
+		if nil != err {
+			return
+		}
+		expression = fmt.Sprintf("%s IN (%s)", field, strings.Join(values, ", "))
+		return
+	}
+
+	if "attribute_type" == f.Type {
+		value, err = b.value(f.Keyword, "S")
+	} else {
+		value, err = b.value(f.Keyword, f.ValueType)
+	}
+	if nil != err {
+		return
+	}
+
+	if comparator, ok := filterComparators[f.Type]; ok {
+		expression = fmt.Sprintf("%s %s %s", field, comparator, value)
+		return
+	}
+
+	if comparator, ok := filterComparators[strings.TrimPrefix(f.Type, "size_")]; ok && strings.HasPrefix(f.Type, "size_") {
+		expression = fmt.Sprintf("size(%s) %s %s", field, comparator, value)
+		return
+	}
+
+	switch f.Type {
+	case "keyword", "contains":
+		expression = fmt.Sprintf("contains(%s, %s)", field, value)
+	case "not_keyword", "not_contains":
+		expression = fmt.Sprintf("NOT contains(%s, %s)", field, value)
+	case "begins_with":
+		expression = fmt.Sprintf("begins_with(%s, %s)", field, value)
+	case "attribute_type":
+		expression = fmt.Sprintf("attribute_type(%s, %s)", field, value)
+	default:
+		err = fmt.Errorf("unsupported filter type (%s)", f.Type)
+	}
+
+	return
+}
+
+// rangeValues accepts either a "from/to" string or a two element list.
+func (f FilterExpression) rangeValues(b *expressionBuilder) (values []string, err error) {
+	var bounds []interface{}
+
+	if keyword, ok := f.Keyword.(string); ok {
+		if !strings.Contains(keyword, "/") {
+			err = fmt.Errorf("%s filter on %s requires a \"from/to\" keyword", f.Type, f.Field)
+			return
+		}
+		for _, bound := range strings.SplitN(keyword, "/", 2) {
+			bounds = append(bounds, bound)
+		}
+	} else {
+		bounds = toInterfaceSlice(f.Keyword)
+		if 2 != len(bounds) {
+			err = fmt.Errorf("%s filter on %s requires exactly two bounds", f.Type, f.Field)
+			return
+		}
+	}
+
+	for _, bound := range bounds {
+		var value string
+
+		value, err = b.value(bound, f.ValueType)
+		if nil != err {
+			return
+		}
+		values = append(values, value)
+	}
+
+	return
+}
+
+func (f FilterExpression) listValues(b *expressionBuilder) (values []string, err error) {
+	var list = toInterfaceSlice(f.Keyword)
+
+	if 0 == len(list) || 100 < len(list) {
+		err = fmt.Errorf("%s filter on %s requires between 1 and 100 values", f.Type, f.Field)
+		return
+	}
+
+	for _, v := range list {
+		var value string
+
+		value, err = b.value(v, f.ValueType)
+		if nil != err {
+			return
+		}
+		values = append(values, value)
+	}
+
+	return
+}
+
+func marshalFilterValue(v interface{}, valueType string) (av types.AttributeValue, err error) {
+	switch valueType {
+	case "":
+		av, err = attributevalue.Marshal(v)
+	case "S":
+		switch value := v.(type) {
+		case string:
+			av = &types.AttributeValueMemberS{Value: value}
+		case nil:
+			err = fmt.Errorf("missing value for type S")
+		default:
+			av = &types.AttributeValueMemberS{Value: fmt.Sprint(value)}
+		}
+	case "N":
+		var number string
+
+		number, err = filterNumber(v)
+		if nil != err {
+			return
+		}
+		av = &types.AttributeValueMemberN{Value: number}
+	case "BOOL":
+		switch value := v.(type) {
+		case bool:
+			av = &types.AttributeValueMemberBOOL{Value: value}
+		case string:
+			var b bool
+
+			b, err = strconv.ParseBool(value)
+			if nil != err {
+				err = fmt.Errorf("invalid BOOL value (%s)", value)
+				return
+			}
+			av = &types.AttributeValueMemberBOOL{Value: b}
+		default:
+			err = fmt.Errorf("invalid BOOL value (%v)", v)
+		}
+	case "NULL":
+		av = &types.AttributeValueMemberNULL{Value: true}
+	case "L":
+		var list = toInterfaceSlice(v)
+		var members = make([]types.AttributeValue, len(list))
+
+		if nil == list {
+			err = fmt.Errorf("invalid L value (%v)", v)
+			return
+		}
+		for i, member := range list {
+			members[i], err = attributevalue.Marshal(member)
+			if nil != err {
+				return
+			}
+		}
+		av = &types.AttributeValueMemberL{Value: members}
+	case "SS", "NS":
+		var list = toInterfaceSlice(v)
+		var members = make([]string, len(list))
+
+		if 0 == len(list) {
+			err = fmt.Errorf("%s value requires at least one member (%v)", valueType, v)
+			return
+		}
+		for i, member := range list {
+			if "NS" == valueType {
+				members[i], err = filterNumber(member)
+				if nil != err {
+					return
+				}
+			} else {
+				members[i] = fmt.Sprint(member)
+			}
+		}
+		if "NS" == valueType {
+			av = &types.AttributeValueMemberNS{Value: members}
+		} else {
+			av = &types.AttributeValueMemberSS{Value: members}
+		}
+	default:
+		err = fmt.Errorf("unsupported value type (%s)", valueType)
+	}
+
+	return
+}
+
+func filterNumber(v interface{}) (number string, err error) {
+	switch value := v.(type) {
+	case string:
+		if _, err = strconv.ParseFloat(value, 64); nil != err {
+			err = fmt.Errorf("invalid N value (%s)", value)
+			return
+		}
+		number = value
+	case json.Number:
+		number = value.String()
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		number = fmt.Sprint(value)
+	case float32:
+		number = strconv.FormatFloat(float64(value), 'f', -1, 32)
+	case float64:
+		number = strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		err = fmt.Errorf("invalid N value (%v)", v)
+	}
+
+	return
+}
+
+func toInterfaceSlice(v interface{}) (list []interface{}) {
+	var rv = reflect.ValueOf(v)
+
+	if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return
+	}
+
+	list = make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+
+	return
+}
+
+// As forces the DynamoDB type used for the keyword of a condition.
+func (f FilterExpression) As(valueType string) FilterExpression {
+	f.ValueType = valueType
+	return f
+}
+
+func And(filters ...FilterExpression) FilterExpression {
+	return FilterExpression{And: filters}
+}
+
+func Or(filters ...FilterExpression) FilterExpression {
+	return FilterExpression{Or: filters}
+}
+
+func Not(filter FilterExpression) FilterExpression {
+	return FilterExpression{Not: &filter}
+}
+
+func Equal(field string, value interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "equal", Keyword: value}
+}
+
+func NotEqual(field string, value interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "not_equal", Keyword: value}
+}
+
+func LessThan(field string, value interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "less_than", Keyword: value}
+}
+
+func LessThanEqual(field string, value interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "less_than_equal", Keyword: value}
+}
+
+func MoreThan(field string, value interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "more_than", Keyword: value}
+}
+
+func MoreThanEqual(field string, value interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "more_than_equal", Keyword: value}
+}
+
+func Between(field string, from interface{}, to interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "between", Keyword: []interface{}{from, to}}
+}
+
+func In(field string, values ...interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "in", Keyword: values}
+}
+
+func Exists(field string) FilterExpression {
+	return FilterExpression{Field: field, Type: "exists"}
+}
+
+func NotExists(field string) FilterExpression {
+	return FilterExpression{Field: field, Type: "not_exists"}
+}
+
+// AttributeType matches attributes of the given DynamoDB type (S, SS, N, NS, B, BS, BOOL, NULL, L, M).
+func AttributeType(field string, attributeType string) FilterExpression {
+	return FilterExpression{Field: field, Type: "attribute_type", Keyword: attributeType}
+}
+
+func BeginsWith(field string, prefix string) FilterExpression {
+	return FilterExpression{Field: field, Type: "begins_with", Keyword: prefix}
+}
+
+func Contains(field string, value interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "contains", Keyword: value}
+}
+
+func NotContains(field string, value interface{}) FilterExpression {
+	return FilterExpression{Field: field, Type: "not_contains", Keyword: value}
+}
+
+// Size compares size(field) with size using one of the comparison types (equal, less_than, ...).
+func Size(field string, filterType string, size int) FilterExpression {
+	return FilterExpression{Field: field, Type: "size_" + filterType, Keyword: size}
+}
+
+func buildFilterExpression(queryOption QueryOption, expressionAttributeValues map[string]types.AttributeValue, expressionAttributeNames map[string]string) (filterExpression string, err error) {
+	var expressions []string
+
+	err = ValidateQueryOption(queryOption)
+	if nil != err {
+		return
+	}
+
+	if nil != queryOption.Filter {
+		var legacy string
+
+		legacy, err = processQueryOptionFilter(queryOption.Filter, expressionAttributeValues, expressionAttributeNames)
+		if nil != err {
+			return
+		}
+		if "" != legacy {
+			expressions = append(expressions, legacy)
+		}
+	}
+
+	if nil != queryOption.Where {
+		var where string
+
+		where, err = queryOption.Where.render(newExpressionBuilder(expressionAttributeNames, expressionAttributeValues))
+		if nil != err {
+			return
+		}
+		expressions = append(expressions, where)
+	}
+
+	if 1 < len(expressions) {
+		filterExpression = fmt.Sprintf("(%s) AND %s", expressions[0], expressions[1])
+	} else if 1 == len(expressions) {
+		filterExpression = expressions[0]
+	}
+
+	return
+}
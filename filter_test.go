@@ -0,0 +1,216 @@
+package ddb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestFilterExpressionRender(t *testing.T) {
+	where := FilterExpression{
+		And: []FilterExpression{
+			{Field: "Status", Type: "equal", Keyword: "ACTIVE"},
+			{Or: []FilterExpression{
+				{Field: "Name", Type: "keyword", Keyword: "kim"},
+				{Not: &FilterExpression{Field: "Deleted", Type: "bool", Keyword: true}},
+			}},
+			{Field: "CreatedTimestamp", Type: "date", Keyword: "2024-01-01/2024-12-31"},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		names := map[string]string{}
+		values := map[string]types.AttributeValue{}
+
+		expression, err := buildFilterExpression(QueryOption{Where: &where}, values, names)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		expected := "(#Status = :_f0 AND (contains(#Name, :_f1) OR NOT (#Deleted = :_f2)) AND #CreatedTimestamp BETWEEN :_f3 AND :_f4)"
+		if expected != expression {
+			t.Fatalf("unexpected expression: %s", expression)
+		}
+		if _, ok := values[":_f2"].(*types.AttributeValueMemberBOOL); !ok {
+			t.Errorf("bool keyword marshalled as %T", values[":_f2"])
+		}
+		if 4 != len(names) {
+			t.Errorf("unexpected names: %v", names)
+		}
+	}
+}
+
+func TestFilterExpressionInvalidNode(t *testing.T) {
+	where := FilterExpression{Field: "Status", Type: "equal", Keyword: "A", Or: []FilterExpression{{Field: "B", Type: "equal", Keyword: "B"}}}
+
+	if _, err := buildFilterExpression(QueryOption{Where: &where}, map[string]types.AttributeValue{}, map[string]string{}); nil == err {
+		t.Error("expected error for node with both field and group")
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	cases := []struct {
+		filter   FilterExpression
+		expected string
+	}{
+		{In("Status", "A", "B"), "#Status IN (:_f0, :_f1)"},
+		{Exists("Email"), "attribute_exists(#Email)"},
+		{NotExists("Email"), "attribute_not_exists(#Email)"},
+		{AttributeType("Amount", "N"), "attribute_type(#Amount, :_f0)"},
+		{BeginsWith("SK", "ORDER#"), "begins_with(#SK, :_f0)"},
+		{NotContains("Tags", "x"), "NOT contains(#Tags, :_f0)"},
+		{Size("Tags", "more_than", 2), "size(#Tags) > :_f0"},
+		{Between("Amount", 10, 20), "#Amount BETWEEN :_f0 AND :_f1"},
+	}
+
+	for _, c := range cases {
+		expression, err := buildFilterExpression(QueryOption{Where: &c.filter}, map[string]types.AttributeValue{}, map[string]string{})
+		if nil != err {
+			t.Fatal(err)
+		}
+		if c.expected != expression {
+			t.Errorf("expected %s, got %s", c.expected, expression)
+		}
+	}
+}
+
+func TestProcessQueryOptionFilter(t *testing.T) {
+	filter := map[string]interface{}{
+		"b": map[string]interface{}{"field": "Status", "type": "in", "keyword": []interface{}{"A", "B"}},
+		"a": map[string]interface{}{"field": "Name", "type": "begins_with", "keyword": "kim"},
+		"c": map[string]interface{}{"field": "Email", "type": "exists", "condition": "or", "position": "child"},
+	}
+
+	expression, err := processQueryOptionFilter(filter, map[string]types.AttributeValue{}, map[string]string{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "begins_with(#Name, :_f0) AND #Status IN (:_f1, :_f2) OR attribute_exists(#Email)" != expression {
+		t.Errorf("unexpected expression: %s", expression)
+	}
+}
+
+func TestLegacyFilterNumbers(t *testing.T) {
+	values := map[string]types.AttributeValue{}
+	filter := map[string]interface{}{
+		// a JSON number decodes as float64
+		"a": map[string]interface{}{"field": "Score", "type": "more_than", "keyword": float64(8)},
+		"b": map[string]interface{}{"field": "Score", "type": "between", "keyword": "1/5.5", "valueType": "N"},
+		"c": map[string]interface{}{"field": "Day", "type": "between", "keyword": "2024-01-01/2024-02-01"},
+		"d": map[string]interface{}{"field": "Day", "type": "more_than", "keyword": "20240101"},
+		"e": map[string]interface{}{"field": "Code", "type": "more_than", "keyword": "0042", "valueType": "S"},
+	}
+
+	if _, err := processQueryOptionFilter(filter, values, map[string]string{}); nil != err {
+		t.Fatal(err)
+	}
+
+	for placeholder, expected := range map[string]string{":_f0": "N", ":_f1": "N", ":_f2": "N", ":_f3": "S", ":_f4": "S", ":_f5": "S", ":_f6": "S"} {
+		var actual = "S"
+		if _, ok := values[placeholder].(*types.AttributeValueMemberN); ok {
+			actual = "N"
+		}
+		if expected != actual {
+			t.Errorf("%s marshalled as %#v, expected %s", placeholder, values[placeholder], expected)
+		}
+	}
+}
+
+func TestFilterTypedValues(t *testing.T) {
+	values := map[string]types.AttributeValue{}
+	where := And(
+		MoreThan("Amount", 100.5),
+		MoreThan("Count", "7").As("N"),
+		Equal("Tags", []string{"a", "b"}).As("SS"),
+		Equal("Archived", nil),
+		In("Codes", 1, 2).As("N"),
+	)
+
+	if _, err := buildFilterExpression(QueryOption{Where: &where}, values, map[string]string{}); nil != err {
+		t.Fatal(err)
+	}
+
+	if n, ok := values[":_f0"].(*types.AttributeValueMemberN); !ok || "100.5" != n.Value {
+		t.Errorf("float keyword marshalled as %#v", values[":_f0"])
+	}
+	if n, ok := values[":_f1"].(*types.AttributeValueMemberN); !ok || "7" != n.Value {
+		t.Errorf("hinted keyword marshalled as %#v", values[":_f1"])
+	}
+	if _, ok := values[":_f2"].(*types.AttributeValueMemberSS); !ok {
+		t.Errorf("set keyword marshalled as %#v", values[":_f2"])
+	}
+	if _, ok := values[":_f3"].(*types.AttributeValueMemberNULL); !ok {
+		t.Errorf("nil keyword marshalled as %#v", values[":_f3"])
+	}
+	if _, ok := values[":_f5"].(*types.AttributeValueMemberN); !ok {
+		t.Errorf("hinted list member marshalled as %#v", values[":_f5"])
+	}
+
+	invalid := MoreThan("Amount", "abc").As("N")
+	if _, err := buildFilterExpression(QueryOption{Where: &invalid}, map[string]types.AttributeValue{}, map[string]string{}); nil == err {
+		t.Error("expected error for invalid number")
+	}
+}
+
+func TestValidateQueryOption(t *testing.T) {
+	where := And(Equal("", "a"), FilterExpression{Field: "Amount", Type: "unknown", Keyword: 1})
+	queryOption := QueryOption{
+		Filter: map[string]interface{}{
+			"a": "not an object",
+			"b": map[string]interface{}{"field": 1, "type": "equal", "keyword": "x"},
+			"c": map[string]interface{}{"field": "Flag", "type": "bool", "keyword": "yes", "valueType": "BOOL", "condition": "xor"},
+			"d": map[string]interface{}{"field": "Status", "type": "equal"},
+		},
+		Where: &where,
+	}
+
+	err := ValidateQueryOption(queryOption)
+
+	validationError, ok := err.(*FilterValidationError)
+	if !ok {
+		t.Fatalf("expected *FilterValidationError, got %v", err)
+	}
+
+	paths := map[string]bool{}
+	for _, problem := range validationError.Problems {
+		paths[problem.Path] = true
+	}
+	for _, path := range []string{"filter.a", "filter.b.field", "filter.c", "filter.c.condition", "filter.d.keyword", "where.and[0]", "where.and[1]"} {
+		if !paths[path] {
+			t.Errorf("missing problem for %s in %v", path, validationError.Problems)
+		}
+	}
+
+	if _, _, err = (&Ddb{}).GetListItem(Key{}, "", queryOption); nil == err {
+		t.Error("expected error for key without PK")
+	}
+}
+
+func TestFilterNestedPath(t *testing.T) {
+	names := map[string]string{}
+	where := And(Equal("Address.City", "Seoul"), Equal("Tags[0]", "vip"), Exists("Orders[1].Items[0].Sku"))
+
+	expression, err := buildFilterExpression(QueryOption{Where: &where}, map[string]types.AttributeValue{}, names)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "(#Address.#City = :_f0 AND #Tags[0] = :_f1 AND attribute_exists(#Orders[1].#Items[0].#Sku))" != expression {
+		t.Errorf("unexpected expression: %s", expression)
+	}
+	if "City" != names["#City"] || "Sku" != names["#Sku"] {
+		t.Errorf("unexpected names: %v", names)
+	}
+
+	projection, err := buildProjection(splitArrayOfField("Name,Address.City,#Tags[1]"), names)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "#Name,#Address.#City,#Tags[1]" != *projection {
+		t.Errorf("unexpected projection: %s", *projection)
+	}
+
+	invalid := Exists("Tags[x]")
+	if _, err = buildFilterExpression(QueryOption{Where: &invalid}, map[string]types.AttributeValue{}, map[string]string{}); nil == err {
+		t.Error("expected error for invalid path")
+	}
+}
@@ -0,0 +1,165 @@
+package ddb
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/seill/log"
+)
+
+// Operation describes one DynamoDB call made by Ddb, retries included. Method
+// is the Ddb method the call is made for, such as GetListItem.
+type Operation struct {
+	Name      string
+	Method    string
+	TableName string
+	IndexName string
+	Input     interface{}
+	Output    interface{}
+	Err       error
+	Attempts  int
+	Duration  time.Duration
+	Capacity  Capacity
+}
+
+// Middleware observes every DynamoDB call. Before runs in registration order
+// and may return a derived context for the call, After runs in reverse order
+// once Output, Err, Attempts, Duration and Capacity are set.
+type Middleware interface {
+	Before(ctx context.Context, operation *Operation) context.Context
+	After(ctx context.Context, operation *Operation)
+}
+
+// MiddlewareFunc adapts a function to a Middleware called after each operation.
+type MiddlewareFunc func(ctx context.Context, operation *Operation)
+
+func (f MiddlewareFunc) Before(ctx context.Context, operation *Operation) context.Context {
+	return ctx
+}
+
+func (f MiddlewareFunc) After(ctx context.Context, operation *Operation) {
+	f(ctx, operation)
+}
+
+// Call describes one call of a Ddb method, which makes Operations DynamoDB
+// calls consuming Capacity in total.
+type Call struct {
+	Method     string
+	TableName  string
+	Err        error
+	Operations int
+	Duration   time.Duration
+	Capacity   Capacity
+}
+
+// CallMiddleware is implemented by middlewares also observing Ddb method calls.
+// BeforeCall runs before the first DynamoDB call of a method, whose Operations
+// then get the context it returns; AfterCall runs once the method returns.
+// Methods called by another method are part of its call.
+type CallMiddleware interface {
+	BeforeCall(ctx context.Context, call *Call) context.Context
+	AfterCall(ctx context.Context, call *Call)
+}
+
+func (r *Ddb) Use(middleware ...Middleware) *Ddb {
+	r.middlewares = append(r.middlewares, middleware...)
+
+	return r
+}
+
+func (r *Ddb) before(ctx context.Context, operation *Operation) context.Context {
+	for _, middleware := range r.middlewares {
+		ctx = middleware.Before(ctx, operation)
+	}
+
+	return ctx
+}
+
+func (r *Ddb) after(ctx context.Context, operation *Operation) {
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		r.middlewares[i].After(ctx, operation)
+	}
+}
+
+// begin starts the call of method, returning the copy of r making it and the
+// function ending it with the error of the method.
+func (r *Ddb) begin(method string) (ddb *Ddb, end func(err error)) {
+	if nil != r.call {
+		return r, func(err error) {}
+	}
+
+	var call = &Call{Method: method, TableName: r.tableName}
+	var ctx = r.context()
+	var start = time.Now()
+
+	for _, middleware := range r.middlewares {
+		if callMiddleware, ok := middleware.(CallMiddleware); ok {
+			ctx = callMiddleware.BeforeCall(ctx, call)
+		}
+	}
+
+	ddb = r.WithContext(ctx)
+	ddb.call = call
+
+	end = func(err error) {
+		call.Err = err
+		call.Duration = time.Since(start)
+
+		for i := len(r.middlewares) - 1; i >= 0; i-- {
+			if callMiddleware, ok := r.middlewares[i].(CallMiddleware); ok {
+				callMiddleware.AfterCall(ctx, call)
+			}
+		}
+	}
+
+	return
+}
+
+func indexNameOf(input interface{}) string {
+	switch in := input.(type) {
+	case *dynamodb.QueryInput:
+		return aws.ToString(in.IndexName)
+	case *dynamodb.ScanInput:
+		return aws.ToString(in.IndexName)
+	}
+
+	return ""
+}
+
+// LogMiddleware logs every operation with seill/log, errors with ErrorJson
+// and the rest with DebugJson.
+type LogMiddleware struct {
+	LogId string
+	// WithPayload adds the SDK input and output to the log.
+	WithPayload bool
+}
+
+func (m LogMiddleware) Before(ctx context.Context, operation *Operation) context.Context {
+	return ctx
+}
+
+func (m LogMiddleware) After(ctx context.Context, operation *Operation) {
+	data := map[string]interface{}{
+		"table":    operation.TableName,
+		"attempts": operation.Attempts,
+		"duration": operation.Duration.String(),
+		"capacity": operation.Capacity,
+	}
+	if "" != operation.IndexName {
+		data["index"] = operation.IndexName
+	}
+	if m.WithPayload {
+		data["input"] = operation.Input
+		data["output"] = operation.Output
+	}
+
+	if nil != operation.Err {
+		data["error"] = operation.Err.Error()
+		log.ErrorJson(m.LogId, "ddb::"+operation.Name, data)
+		return
+	}
+
+	log.DebugJson(m.LogId, "ddb::"+operation.Name, data)
+}
@@ -0,0 +1,134 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type recordingMiddleware struct {
+	name  string
+	calls *[]string
+}
+
+type middlewareKey struct{}
+
+func (m recordingMiddleware) Before(ctx context.Context, operation *Operation) context.Context {
+	*m.calls = append(*m.calls, "before "+m.name)
+	return context.WithValue(ctx, middlewareKey{}, m.name)
+}
+
+func (m recordingMiddleware) After(ctx context.Context, operation *Operation) {
+	*m.calls = append(*m.calls, "after "+m.name)
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	var operations []*Operation
+
+	r := New(nil, "table").
+		SetRetryPolicy(RetryPolicy{MaxAttempts: 2}).
+		Use(recordingMiddleware{name: "a", calls: &calls}, recordingMiddleware{name: "b", calls: &calls}).
+		Use(MiddlewareFunc(func(ctx context.Context, operation *Operation) {
+			operations = append(operations, operation)
+		}))
+
+	attempts := 0
+	query := func(ctx context.Context, input *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		if "b" != ctx.Value(middlewareKey{}) {
+			t.Error("call should receive the context returned by Before")
+		}
+		attempts++
+		if 1 == attempts {
+			return nil, &types.ProvisionedThroughputExceededException{}
+		}
+		return &dynamodb.QueryOutput{Count: 1, ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)}}, nil
+	}
+
+	if _, err := invoke(r, &dynamodb.QueryInput{IndexName: aws.String(GSI1)}, query); nil != err {
+		t.Fatal(err)
+	}
+
+	expected := []string{"before a", "before b", "after b", "after a"}
+	if len(expected) != len(calls) {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+	for i := range expected {
+		if expected[i] != calls[i] {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+	}
+
+	operation := operations[0]
+	if "Query" != operation.Name || GSI1 != operation.IndexName || 2 != operation.Attempts || nil == operation.Output || 0.5 != operation.Capacity.CapacityUnits {
+		t.Errorf("unexpected operation: %+v", operation)
+	}
+
+	failure := errors.New("failure")
+	_, _ = invoke(r, &dynamodb.GetItemInput{}, func(ctx context.Context, input *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		time.Sleep(time.Millisecond)
+		return nil, failure
+	})
+	if operation = operations[1]; failure != operation.Err || nil != operation.Output || 0 == operation.Duration {
+		t.Errorf("unexpected operation: %+v", operation)
+	}
+}
+
+type callRecorder struct {
+	recordingMiddleware
+	ended []*Call
+}
+
+func (m *callRecorder) BeforeCall(ctx context.Context, call *Call) context.Context {
+	*m.calls = append(*m.calls, "begin "+call.Method)
+	return context.WithValue(ctx, middlewareKey{}, call.Method)
+}
+
+func (m *callRecorder) AfterCall(ctx context.Context, call *Call) {
+	*m.calls = append(*m.calls, "end "+call.Method)
+	m.ended = append(m.ended, call)
+}
+
+func TestCallMiddleware(t *testing.T) {
+	var calls []string
+	var operations []*Operation
+
+	recorder := &callRecorder{recordingMiddleware: recordingMiddleware{name: "a", calls: &calls}}
+	r := New(nil, "table").Use(recorder, MiddlewareFunc(func(ctx context.Context, operation *Operation) {
+		operations = append(operations, operation)
+	}))
+
+	getItem := func(ctx context.Context, input *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(1)}}, nil
+	}
+
+	outer, end := r.begin("Outer")
+	inner, endInner := outer.begin("Inner")
+	_, _ = invoke(outer, &dynamodb.GetItemInput{}, getItem)
+	_, _ = invoke(inner, &dynamodb.GetItemInput{}, getItem)
+	endInner(nil)
+	end(nil)
+
+	expected := []string{"begin Outer", "before a", "after a", "before a", "after a", "end Outer"}
+	if len(expected) != len(calls) {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+	for i := range expected {
+		if expected[i] != calls[i] {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+	}
+
+	call := recorder.ended[0]
+	if 2 != call.Operations || 2 != call.Capacity.CapacityUnits || "table" != call.TableName {
+		t.Errorf("unexpected call: %+v", call)
+	}
+	if "Outer" != operations[0].Method || "Outer" != operations[1].Method {
+		t.Errorf("unexpected methods: %s %s", operations[0].Method, operations[1].Method)
+	}
+}
@@ -0,0 +1,384 @@
+package ddb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const DefaultMaxInMemorySortItems = 1000
+
+var ErrOrderNotSupported = errors.New("order not supported")
+
+// SetIndexOrderField declares that items of indexName are sorted by field, so
+// that QueryOption.Order on field can be served when the key reads that index.
+// An empty indexName stands for the base table.
+func (r *Ddb) SetIndexOrderField(indexName string, field string) *Ddb {
+	if nil == r.indexOrderFields {
+		r.indexOrderFields = map[string]string{}
+	}
+	r.indexOrderFields[indexName] = field
+
+	return r
+}
+
+// SetMaxInMemorySortItems bounds the number of items GetListItem loads when
+// QueryOption.InMemorySort is used.
+func (r *Ddb) SetMaxInMemorySortItems(maxItems int) *Ddb {
+	r.maxInMemorySortItems = maxItems
+
+	return r
+}
+
+func (r *Ddb) indexSortedBy(indexName string, field string) bool {
+	return indexName+"SK" == field || (nil != r.indexOrderFields && "" != r.indexOrderFields[indexName] && r.indexOrderFields[indexName] == field)
+}
+
+// resolveOrder maps QueryOption.Order onto the index read by key. A single
+// order field the index is sorted by flips ScanIndexForward for the direction.
+// Another index is never picked: its keys are not the ones of key. Anything
+// else needs QueryOption.InMemorySort.
+func (r *Ddb) resolveOrder(key Key, queryOption QueryOption) (resolvedOption QueryOption, inMemory bool, err error) {
+	resolvedOption = queryOption
+
+	if 0 == len(queryOption.Order) {
+		return
+	}
+
+	for _, order := range queryOption.Order {
+		if "" == order.Field {
+			err = fmt.Errorf("%w: order field is required", ErrOrderNotSupported)
+			return
+		}
+		if _, err = orderAscending(order); nil != err {
+			return
+		}
+	}
+
+	if 1 == len(queryOption.Order) {
+		var order = queryOption.Order[0]
+		var ascending, _ = orderAscending(order)
+
+		if r.indexSortedBy(aws.ToString(key.IndexName), order.Field) {
+			resolvedOption.ScanIndexForward = aws.Bool(ascending)
+			return
+		}
+	}
+
+	if !queryOption.InMemorySort {
+		err = fmt.Errorf("%w: %s is not sorted by %s, set InMemorySort to sort in memory", ErrOrderNotSupported, describeIndex(key.IndexName), describeOrder(queryOption.Order))
+		return
+	}
+	// sorted items are returned in a single page, there is nothing to resume from
+	if nil != queryOption.Page && !emptyLastEvaluatedKey(queryOption.Page.LastEvaluatedKey) {
+		err = fmt.Errorf("%w: LastEvaluatedKey cannot resume an in memory sort", ErrOrderNotSupported)
+		return
+	}
+
+	inMemory = true
+
+	return
+}
+
+func orderAscending(order QueryOptionOrder) (ascending bool, err error) {
+	switch strings.ToLower(order.Direction) {
+	case "", "asc", "ascending":
+		ascending = true
+	case "desc", "descending":
+	default:
+		err = fmt.Errorf("%w: invalid direction (%s) for %s", ErrOrderNotSupported, order.Direction, order.Field)
+	}
+
+	return
+}
+
+func emptyLastEvaluatedKey(key interface{}) bool {
+	var v = reflect.ValueOf(key)
+
+	for reflect.Pointer == v.Kind() || reflect.Interface == v.Kind() {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Map, reflect.Slice:
+		return 0 == v.Len()
+	}
+
+	return false
+}
+
+func describeIndex(indexName *string) string {
+	if nil == indexName {
+		return "the base table"
+	}
+
+	return "index " + *indexName
+}
+
+func describeOrder(orders []QueryOptionOrder) string {
+	var fields = make([]string, len(orders))
+
+	for i, order := range orders {
+		fields[i] = order.Field
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// queryAndSort loads every page of input, up to maxInMemorySortItems, and sorts
+// the items. Only the first pageSize of them are kept when pageSize is positive.
+func (r *Ddb) queryAndSort(input *dynamodb.QueryInput, orders []QueryOptionOrder) (items []map[string]types.AttributeValue, err error) {
+	var maxItems = r.maxInMemorySortItems
+	var pageSize = aws.ToInt32(input.Limit)
+	var added []string
+
+	if 0 >= maxItems {
+		maxItems = DefaultMaxInMemorySortItems
+	}
+
+	added, err = prepareSortQuery(input, orders)
+	if nil != err {
+		return
+	}
+
+	for {
+		var output *dynamodb.QueryOutput
+
+		output, err = invoke(r, input, r.dynamoDb.Query)
+		if nil != err {
+			return
+		}
+
+		items = append(items, output.Items...)
+		if len(items) > maxItems {
+			err = fmt.Errorf("%w: more than %d items to sort in memory", ErrOrderNotSupported, maxItems)
+			items = nil
+			return
+		}
+
+		if nil == output.LastEvaluatedKey {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+
+	sortItems(items, orders)
+	if 0 < pageSize && int(pageSize) < len(items) {
+		items = items[:pageSize]
+	}
+	for _, item := range items {
+		for _, path := range added {
+			removeAttributePath(item, path)
+		}
+	}
+
+	return
+}
+
+// prepareSortQuery reads every item of input, along with the fields to sort on.
+// added are the order fields to remove from the sorted items: those the
+// projection of input did not cover, unless they cover part of it.
+func prepareSortQuery(input *dynamodb.QueryInput, orders []QueryOptionOrder) (added []string, err error) {
+	input.Limit = nil
+	input.ExclusiveStartKey = nil
+
+	if nil != input.ProjectionExpression {
+		var paths []string
+
+		for _, expression := range strings.Split(*input.ProjectionExpression, ",") {
+			paths = append(paths, resolveNames(expression, input.ExpressionAttributeNames))
+		}
+		var projected = slices.Clone(paths)
+
+		for _, order := range orders {
+			if slices.ContainsFunc(projected, func(path string) bool { return coversPath(path, order.Field) }) {
+				continue
+			}
+			paths = append(paths, order.Field)
+			if !slices.ContainsFunc(projected, func(path string) bool { return coversPath(order.Field, path) }) {
+				added = append(added, order.Field)
+			}
+		}
+
+		input.ProjectionExpression, err = buildProjection(paths, input.ExpressionAttributeNames)
+		if nil != err {
+			return
+		}
+		pruneExpressionAttributeNames(input)
+	}
+
+	return
+}
+
+// pruneExpressionAttributeNames drops the names no expression of input uses
+// anymore, which DynamoDB rejects.
+func pruneExpressionAttributeNames(input *dynamodb.QueryInput) {
+	var used = map[string]bool{}
+
+	for _, expression := range []*string{input.KeyConditionExpression, input.FilterExpression, input.ProjectionExpression} {
+		for _, placeholder := range reNamePlaceholder.FindAllString(aws.ToString(expression), -1) {
+			used[placeholder] = true
+		}
+	}
+
+	for placeholder := range input.ExpressionAttributeNames {
+		if !used[placeholder] {
+			delete(input.ExpressionAttributeNames, placeholder)
+		}
+	}
+}
+
+func sortItems(items []map[string]types.AttributeValue, orders []QueryOptionOrder) {
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, order := range orders {
+			var ascending, _ = orderAscending(order)
+			var a = attributeAtPath(items[i], order.Field)
+			var b = attributeAtPath(items[j], order.Field)
+
+			// missing attributes always sort last
+			if nil == a || nil == b {
+				if (nil == a) == (nil == b) {
+					continue
+				}
+				return nil == b
+			}
+
+			if c := compareAttributeValues(a, b); 0 != c {
+				return (0 > c) == ascending
+			}
+		}
+
+		return false
+	})
+}
+
+func attributeAtPath(item map[string]types.AttributeValue, path string) (value types.AttributeValue) {
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+
+	for _, segment := range strings.Split(path, ".") {
+		var match = rePathSegment.FindStringSubmatch(segment)
+
+		if nil == match {
+			return nil
+		}
+
+		m, ok := current.(*types.AttributeValueMemberM)
+		if !ok {
+			return nil
+		}
+		current = m.Value[match[1]]
+
+		for _, index := range strings.Split(strings.Trim(match[2], "[]"), "][") {
+			if "" == index {
+				continue
+			}
+
+			l, ok := current.(*types.AttributeValueMemberL)
+			i, _ := strconv.Atoi(index)
+			if !ok || i >= len(l.Value) {
+				return nil
+			}
+			current = l.Value[i]
+		}
+	}
+
+	value = current
+
+	return
+}
+
+// removeAttributePath removes the attribute at path from item, along with the
+// maps and lists left empty, as if path had not been projected.
+func removeAttributePath(item map[string]types.AttributeValue, path string) {
+	var steps []interface{}
+
+	for _, segment := range strings.Split(path, ".") {
+		var match = rePathSegment.FindStringSubmatch(segment)
+
+		if nil == match {
+			return
+		}
+
+		steps = append(steps, match[1])
+		for _, index := range strings.Split(strings.Trim(match[2], "[]"), "][") {
+			if i, err := strconv.Atoi(index); nil == err {
+				steps = append(steps, i)
+			}
+		}
+	}
+
+	removeAttributeSteps(&types.AttributeValueMemberM{Value: item}, steps)
+}
+
+// removeAttributeSteps reports whether value is to be removed from its parent.
+func removeAttributeSteps(value types.AttributeValue, steps []interface{}) bool {
+	if 0 == len(steps) {
+		return true
+	}
+
+	switch v := value.(type) {
+	case *types.AttributeValueMemberM:
+		name, ok := steps[0].(string)
+		if child, found := v.Value[name]; ok && found && removeAttributeSteps(child, steps[1:]) {
+			delete(v.Value, name)
+			return 0 == len(v.Value)
+		}
+	case *types.AttributeValueMemberL:
+		i, ok := steps[0].(int)
+		if ok && i < len(v.Value) && removeAttributeSteps(v.Value[i], steps[1:]) {
+			v.Value = slices.Delete(v.Value, i, i+1)
+			return 0 == len(v.Value)
+		}
+	}
+
+	return false
+}
+
+func compareAttributeValues(a types.AttributeValue, b types.AttributeValue) int {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS:
+		if bv, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(av.Value, bv.Value)
+		}
+	case *types.AttributeValueMemberN:
+		if bv, ok := b.(*types.AttributeValueMemberN); ok {
+			x, _, errA := big.ParseFloat(av.Value, 10, 200, big.ToNearestEven)
+			y, _, errB := big.ParseFloat(bv.Value, 10, 200, big.ToNearestEven)
+			if nil == errA && nil == errB {
+				return x.Cmp(y)
+			}
+			return strings.Compare(av.Value, bv.Value)
+		}
+	case *types.AttributeValueMemberB:
+		if bv, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(av.Value, bv.Value)
+		}
+	case *types.AttributeValueMemberBOOL:
+		if bv, ok := b.(*types.AttributeValueMemberBOOL); ok {
+			if av.Value == bv.Value {
+				return 0
+			}
+			if bv.Value {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return strings.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b))
+}
@@ -0,0 +1,73 @@
+package ddb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestResolveOrder(t *testing.T) {
+	r := New(nil, "table").SetIndexOrderField(GSI2, "CreatedTimestamp")
+
+	queryOption, inMemory, err := r.resolveOrder(Key{PK: aws.String("pk"), IndexName: aws.String(GSI1)}, QueryOption{Order: []QueryOptionOrder{{Field: "GSI1SK", Direction: "desc"}}})
+	if nil != err || inMemory || *queryOption.ScanIndexForward {
+		t.Errorf("expected descending index order, got %v %v %v", queryOption.ScanIndexForward, inMemory, err)
+	}
+
+	// GSI2 is sorted by CreatedTimestamp, but its keys are not the ones of a base table key
+	if _, _, err = r.resolveOrder(Key{PK: aws.String("pk")}, QueryOption{Order: []QueryOptionOrder{{Field: "CreatedTimestamp"}}}); !errors.Is(err, ErrOrderNotSupported) {
+		t.Errorf("expected ErrOrderNotSupported, got %v", err)
+	}
+
+	queryOption, _, err = r.resolveOrder(Key{PK: aws.String("pk"), IndexName: aws.String(GSI2)}, QueryOption{Order: []QueryOptionOrder{{Field: "CreatedTimestamp"}}})
+	if nil != err || !*queryOption.ScanIndexForward {
+		t.Errorf("expected ascending %s order, got %v %v", GSI2, queryOption.ScanIndexForward, err)
+	}
+
+	_, _, err = r.resolveOrder(Key{PK: aws.String("pk"), IndexName: aws.String(GSI1)}, QueryOption{Order: []QueryOptionOrder{{Field: "Name"}}})
+	if !errors.Is(err, ErrOrderNotSupported) {
+		t.Errorf("expected ErrOrderNotSupported, got %v", err)
+	}
+
+	_, inMemory, err = r.resolveOrder(Key{PK: aws.String("pk"), IndexName: aws.String(GSI1)}, QueryOption{Order: []QueryOptionOrder{{Field: "Name"}}, InMemorySort: true})
+	if nil != err || !inMemory {
+		t.Errorf("expected in memory sort, got %v %v", inMemory, err)
+	}
+}
+
+func TestSortItems(t *testing.T) {
+	items := []map[string]types.AttributeValue{
+		{"Id": &types.AttributeValueMemberS{Value: "a"}, "Group": &types.AttributeValueMemberS{Value: "x"}, "Amount": &types.AttributeValueMemberN{Value: "9"}},
+		{"Id": &types.AttributeValueMemberS{Value: "b"}, "Group": &types.AttributeValueMemberS{Value: "x"}, "Amount": &types.AttributeValueMemberN{Value: "10"}},
+		{"Id": &types.AttributeValueMemberS{Value: "c"}, "Group": &types.AttributeValueMemberS{Value: "w"}},
+		{"Id": &types.AttributeValueMemberS{Value: "d"}, "Group": &types.AttributeValueMemberS{Value: "w"}, "Amount": &types.AttributeValueMemberN{Value: "1"}},
+	}
+
+	sortItems(items, []QueryOptionOrder{{Field: "Group"}, {Field: "Amount", Direction: "desc"}})
+
+	var ids string
+	for _, item := range items {
+		ids += item["Id"].(*types.AttributeValueMemberS).Value
+	}
+	if "dcba" != ids {
+		t.Errorf("unexpected order: %s", ids)
+	}
+}
+
+func TestBuildQueryInputBaseTable(t *testing.T) {
+	r := New(nil, "table")
+
+	input, err := r.buildQueryInput(Key{PK: aws.String("USER#1")}, "", QueryOption{ConsistentRead: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "#PK = :gsipk" != *input.KeyConditionExpression || !*input.ConsistentRead || 1 != len(input.ExpressionAttributeNames) {
+		t.Errorf("unexpected input: %s %v %v", *input.KeyConditionExpression, input.ConsistentRead, input.ExpressionAttributeNames)
+	}
+
+	if _, err = r.buildQueryInput(Key{PK: aws.String("USER#1"), IndexName: aws.String(GSI1)}, "", QueryOption{ConsistentRead: true}); !errors.Is(err, ErrConsistentReadOnGsi) {
+		t.Errorf("expected ErrConsistentReadOnGsi, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+// Package otelddb reports ddb operations as OpenTelemetry spans and metrics.
+//
+//	middleware, err := otelddb.New()
+//	repository := ddb.New(client, tableName).Use(middleware)
+package otelddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
+	"github.com/seill/ddb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/seill/ddb/otelddb"
+
+type Option func(m *Middleware)
+
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(m *Middleware) {
+		m.tracerProvider = provider
+	}
+}
+
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(m *Middleware) {
+		m.meterProvider = provider
+	}
+}
+
+// Middleware implements ddb.Middleware and ddb.CallMiddleware. Each Ddb method
+// gets an internal span named after it, recorded in the ddb.method.duration
+// histogram, under which each of its operations gets a client span carrying
+// table, index, operation, item count and consumed capacity. Operations are
+// recorded in the ddb.operation.duration histogram and, on failure, in the
+// ddb.operation.errors counter by error type.
+type Middleware struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	tracer         trace.Tracer
+	methodDuration metric.Float64Histogram
+	duration       metric.Float64Histogram
+	errors         metric.Int64Counter
+	capacity       metric.Float64Counter
+}
+
+func New(options ...Option) (m *Middleware, err error) {
+	m = &Middleware{}
+
+	for _, option := range options {
+		option(m)
+	}
+	if nil == m.tracerProvider {
+		m.tracerProvider = otel.GetTracerProvider()
+	}
+	if nil == m.meterProvider {
+		m.meterProvider = otel.GetMeterProvider()
+	}
+
+	m.tracer = m.tracerProvider.Tracer(instrumentationName)
+	meter := m.meterProvider.Meter(instrumentationName)
+
+	m.methodDuration, err = meter.Float64Histogram("ddb.method.duration", metric.WithUnit("s"), metric.WithDescription("Duration of Ddb methods, all their DynamoDB operations included."))
+	if nil != err {
+		return
+	}
+	m.duration, err = meter.Float64Histogram("ddb.operation.duration", metric.WithUnit("s"), metric.WithDescription("Duration of DynamoDB operations, retries included."))
+	if nil != err {
+		return
+	}
+	m.errors, err = meter.Int64Counter("ddb.operation.errors", metric.WithDescription("Failed DynamoDB operations by error type."))
+	if nil != err {
+		return
+	}
+	m.capacity, err = meter.Float64Counter("ddb.operation.consumed_capacity", metric.WithUnit("{capacity_unit}"), metric.WithDescription("Capacity units consumed by DynamoDB operations."))
+
+	return
+}
+
+func (m *Middleware) BeforeCall(ctx context.Context, call *ddb.Call) context.Context {
+	ctx, _ = m.tracer.Start(ctx, "ddb."+call.Method,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(callAttributes(call)...),
+	)
+
+	return ctx
+}
+
+func (m *Middleware) AfterCall(ctx context.Context, call *ddb.Call) {
+	var span = trace.SpanFromContext(ctx)
+	var attributes = callAttributes(call)
+
+	span.SetAttributes(
+		attribute.Int("ddb.operations", call.Operations),
+		attribute.Float64("aws.dynamodb.consumed_capacity", call.Capacity.CapacityUnits),
+	)
+
+	if nil != call.Err {
+		errorType := ErrorType(call.Err)
+
+		span.RecordError(call.Err)
+		span.SetStatus(codes.Error, call.Err.Error())
+		span.SetAttributes(attribute.String("error.type", errorType))
+		attributes = append(attributes, attribute.String("error.type", errorType))
+	}
+
+	m.methodDuration.Record(ctx, call.Duration.Seconds(), metric.WithAttributes(attributes...))
+
+	span.End()
+}
+
+func (m *Middleware) Before(ctx context.Context, operation *ddb.Operation) context.Context {
+	ctx, _ = m.tracer.Start(ctx, "DynamoDB."+operation.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(operationAttributes(operation)...),
+	)
+
+	return ctx
+}
+
+func (m *Middleware) After(ctx context.Context, operation *ddb.Operation) {
+	var span = trace.SpanFromContext(ctx)
+	var attributes = metric.WithAttributes(operationAttributes(operation)...)
+
+	span.SetAttributes(
+		attribute.Int("aws.dynamodb.attempts", operation.Attempts),
+		attribute.Float64("aws.dynamodb.consumed_capacity", operation.Capacity.CapacityUnits),
+	)
+	if count, ok := itemCount(operation.Output); ok {
+		span.SetAttributes(attribute.Int("aws.dynamodb.item_count", count))
+	}
+
+	m.duration.Record(ctx, operation.Duration.Seconds(), attributes)
+	if 0 < operation.Capacity.CapacityUnits {
+		m.capacity.Add(ctx, operation.Capacity.CapacityUnits, attributes)
+	}
+
+	if nil != operation.Err {
+		errorType := ErrorType(operation.Err)
+
+		span.RecordError(operation.Err)
+		span.SetStatus(codes.Error, operation.Err.Error())
+		span.SetAttributes(attribute.String("error.type", errorType))
+		m.errors.Add(ctx, 1, metric.WithAttributes(append(operationAttributes(operation), attribute.String("error.type", errorType))...))
+	}
+
+	span.End()
+}
+
+func operationAttributes(operation *ddb.Operation) (attributes []attribute.KeyValue) {
+	attributes = []attribute.KeyValue{
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", operation.Name),
+		attribute.StringSlice("aws.dynamodb.table_names", []string{operation.TableName}),
+	}
+	if "" != operation.Method {
+		attributes = append(attributes, attribute.String("ddb.method", operation.Method))
+	}
+	if "" != operation.IndexName {
+		attributes = append(attributes, attribute.String("aws.dynamodb.index_name", operation.IndexName))
+	}
+
+	return
+}
+
+func callAttributes(call *ddb.Call) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("ddb.method", call.Method),
+		attribute.StringSlice("aws.dynamodb.table_names", []string{call.TableName}),
+	}
+}
+
+// ErrorType names an error by its DynamoDB error code, or its Go type.
+func ErrorType(err error) string {
+	var apiError smithy.APIError
+
+	if errors.As(err, &apiError) {
+		return apiError.ErrorCode()
+	}
+
+	return fmt.Sprintf("%T", err)
+}
+
+func itemCount(output interface{}) (count int, ok bool) {
+	ok = true
+
+	switch out := output.(type) {
+	case *dynamodb.GetItemOutput:
+		if nil != out.Item {
+			count = 1
+		}
+	case *dynamodb.QueryOutput:
+		count = int(out.Count)
+	case *dynamodb.ScanOutput:
+		count = int(out.Count)
+	case *dynamodb.BatchGetItemOutput:
+		for _, items := range out.Responses {
+			count += len(items)
+		}
+	default:
+		ok = false
+	}
+
+	return
+}
@@ -0,0 +1,133 @@
+package otelddb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/seill/ddb"
+	"github.com/seill/ddb/ddbtest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddleware(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+
+	middleware, err := New(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	query := &ddb.Operation{Name: "Query", TableName: "table", IndexName: ddb.GSI1, Attempts: 1, Duration: time.Millisecond}
+	ctx := middleware.Before(context.TODO(), query)
+	query.Output = &dynamodb.QueryOutput{Count: 3}
+	query.Capacity.CapacityUnits = 1.5
+	middleware.After(ctx, query)
+
+	put := &ddb.Operation{Name: "PutItem", TableName: "table", Attempts: 3, Duration: time.Millisecond}
+	ctx = middleware.Before(context.TODO(), put)
+	put.Err = &types.ProvisionedThroughputExceededException{Message: aws.String("slow down")}
+	middleware.After(ctx, put)
+
+	spans := exporter.GetSpans()
+	if 2 != len(spans) {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	attributes := attribute.NewSet(spans[0].Attributes...)
+	if value, _ := attributes.Value("aws.dynamodb.item_count"); 3 != value.AsInt64() {
+		t.Errorf("unexpected item count: %v", value)
+	}
+	if value, _ := attributes.Value("aws.dynamodb.index_name"); ddb.GSI1 != value.AsString() {
+		t.Errorf("unexpected index: %v", value)
+	}
+	if value, _ := attributes.Value("aws.dynamodb.consumed_capacity"); 1.5 != value.AsFloat64() {
+		t.Errorf("unexpected capacity: %v", value)
+	}
+	if "DynamoDB.PutItem" != spans[1].Name || codes.Error != spans[1].Status.Code {
+		t.Errorf("unexpected error span: %s %v", spans[1].Name, spans[1].Status)
+	}
+
+	var data metricdata.ResourceMetrics
+	if err = reader.Collect(context.TODO(), &data); nil != err {
+		t.Fatal(err)
+	}
+
+	metrics := map[string]metricdata.Aggregation{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	if histogram, ok := metrics["ddb.operation.duration"].(metricdata.Histogram[float64]); !ok || 2 != len(histogram.DataPoints) {
+		t.Errorf("unexpected duration histogram: %#v", metrics["ddb.operation.duration"])
+	}
+	errorCounter, ok := metrics["ddb.operation.errors"].(metricdata.Sum[int64])
+	if !ok || 1 != len(errorCounter.DataPoints) {
+		t.Fatalf("unexpected error counter: %#v", metrics["ddb.operation.errors"])
+	}
+	if value, _ := errorCounter.DataPoints[0].Attributes.Value("error.type"); "ProvisionedThroughputExceededException" != value.AsString() {
+		t.Errorf("unexpected error type: %v", value)
+	}
+}
+
+func TestMethodSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	middleware, err := New(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))),
+		WithMeterProvider(sdkmetric.NewMeterProvider()),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	r := ddb.New(ddbtest.NewFakeWithTable("table"), "table").Use(middleware)
+	if err = r.CreateItem(map[string]interface{}{"PK": "USER#Alice", "SK": "PROFILE", "GSI1PK": "USER", "GSI1SK": "Alice"}); nil != err {
+		t.Fatal(err)
+	}
+	exporter.Reset()
+
+	// a Query on GSI1 and a BatchGetItem on the base table
+	_, _, err = r.GetListItem(ddb.Key{PK: aws.String("USER"), IndexName: aws.String(ddb.GSI1)}, "", ddb.QueryOption{FetchFromBase: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if 3 != len(spans) {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	parent := spans[len(spans)-1]
+	if "ddb.GetListItem" != parent.Name {
+		t.Fatalf("unexpected parent span: %s", parent.Name)
+	}
+	attributes := attribute.NewSet(parent.Attributes...)
+	if value, _ := attributes.Value("ddb.operations"); 2 != value.AsInt64() {
+		t.Errorf("unexpected operations: %v", value)
+	}
+
+	for _, span := range spans[:2] {
+		if parent.SpanContext.SpanID() != span.Parent.SpanID() {
+			t.Errorf("%s is not nested under %s", span.Name, parent.Name)
+		}
+		attributes = attribute.NewSet(span.Attributes...)
+		if value, _ := attributes.Value("ddb.method"); "GetListItem" != value.AsString() {
+			t.Errorf("unexpected method of %s: %v", span.Name, value)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package ddb
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// splitArrayOfField reads the legacy comma separated field list, where fields
+// may be prefixed with "#".
+func splitArrayOfField(arrayOfField string) (paths []string) {
+	if "" == arrayOfField {
+		return
+	}
+
+	for _, field := range strings.Split(arrayOfField, ",") {
+		paths = append(paths, strings.TrimSpace(strings.ReplaceAll(field, "#", "")))
+	}
+
+	return
+}
+
+func buildProjection(paths []string, expressionAttributeNames map[string]string) (projectionExpression *string, err error) {
+	if 0 == len(paths) {
+		return
+	}
+
+	var builder = newExpressionBuilder(expressionAttributeNames, nil)
+	var projected []string
+	var expressions []string
+
+	for _, path := range paths {
+		projected = addProjectionPath(projected, path)
+	}
+
+	for _, path := range projected {
+		var expression string
+
+		expression, err = builder.path(path)
+		if err != nil {
+			return
+		}
+		expressions = append(expressions, expression)
+	}
+
+	projectionExpression = aws.String(strings.Join(expressions, ","))
+
+	return
+}
+
+// coversPath reports whether the document path parent is path or one of its ancestors.
+func coversPath(parent string, path string) bool {
+	if !strings.HasPrefix(path, parent) {
+		return false
+	}
+
+	rest := path[len(parent):]
+
+	return "" == rest || '.' == rest[0] || '[' == rest[0]
+}
+
+// addProjectionPath adds path unless a projected path covers it, dropping the
+// projected paths it covers: DynamoDB rejects overlapping paths.
+func addProjectionPath(projected []string, path string) []string {
+	for _, p := range projected {
+		if coversPath(p, path) {
+			return projected
+		}
+	}
+
+	projected = slices.DeleteFunc(projected, func(p string) bool {
+		return coversPath(path, p)
+	})
+
+	return append(projected, path)
+}
+
+var reNamePlaceholder = regexp.MustCompile(`#[A-Za-z0-9_]+`)
+
+// resolveNames substitutes the attribute names of the placeholders of expression.
+func resolveNames(expression string, expressionAttributeNames map[string]string) string {
+	return reNamePlaceholder.ReplaceAllStringFunc(expression, func(placeholder string) string {
+		if name, ok := expressionAttributeNames[placeholder]; ok {
+			return name
+		}
+		return placeholder
+	})
+}
+
+// ProjectionOf lists the attribute names a struct is decoded from, following
+// dynamodbav tags and flattening embedded structs such as DynamoDbMetaData.
+func ProjectionOf(v interface{}) (paths []string) {
+	var t = reflect.TypeOf(v)
+
+	for nil != t && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if nil == t || t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		var field = t.Field(i)
+		var name = field.Name
+		var tag = field.Tag.Get("dynamodbav")
+
+		if "" != tag {
+			name = strings.Split(tag, ",")[0]
+			if "-" == name {
+				continue
+			}
+		}
+
+		if field.Anonymous && ("" == tag || "" == name) {
+			var embedded = field.Type
+
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				paths = append(paths, ProjectionOf(reflect.New(embedded).Interface())...)
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if "" == name {
+			name = field.Name
+		}
+
+		paths = append(paths, name)
+	}
+
+	return
+}
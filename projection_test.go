@@ -0,0 +1,93 @@
+package ddb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type projectionTest struct {
+	DynamoDbMetaData
+	Name     string
+	Amount   int    `dynamodbav:"Total,omitempty"`
+	Internal string `dynamodbav:"-"`
+	hidden   string
+}
+
+func TestProjectionOf(t *testing.T) {
+	paths := ProjectionOf(&projectionTest{})
+
+	expected := append(ProjectionOf(DynamoDbMetaData{}), "Name", "Total")
+	if !reflect.DeepEqual(expected, paths) {
+		t.Errorf("unexpected projection: %v", paths)
+	}
+	if "Id" != expected[0] {
+		t.Errorf("embedded IDynamoDbRecord should be skipped: %v", expected)
+	}
+
+	names := map[string]string{}
+	projection, err := buildProjection([]string{"Name", "Name", "Address.City"}, names)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "#Name,#Address.#City" != *projection {
+		t.Errorf("unexpected projection expression: %s", *projection)
+	}
+}
+
+func TestOverlappingProjection(t *testing.T) {
+	names := map[string]string{}
+	projection, err := buildProjection([]string{"Address.City", "Items[1]", "Address", "Items[10].Name", "Items[1].Name"}, names)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "#Items[1],#Address,#Items[10].#Name" != *projection || 3 != len(names) {
+		t.Errorf("unexpected projection expression: %s %v", *projection, names)
+	}
+
+	// the order field is nested under a projected map, or covers a projected path
+	input := &dynamodb.QueryInput{
+		KeyConditionExpression:   aws.String("#PK = :gsipk"),
+		ExpressionAttributeNames: map[string]string{"#PK": "PK"},
+	}
+	input.ProjectionExpression, _ = buildProjection([]string{"Address", "Tags.Color", "Name"}, input.ExpressionAttributeNames)
+	added, err := prepareSortQuery(input, []QueryOptionOrder{{Field: "Address.City"}, {Field: "Tags"}, {Field: "Score"}})
+	if nil != err {
+		t.Fatal(err)
+	}
+	// Tags is kept in the results as Tags.Color was asked for
+	if 1 != len(added) || "Score" != added[0] {
+		t.Errorf("unexpected added fields: %v", added)
+	}
+	if "#Address,#Name,#Tags,#Score" != *input.ProjectionExpression {
+		t.Errorf("unexpected sort projection: %s", *input.ProjectionExpression)
+	}
+	if _, ok := input.ExpressionAttributeNames["#Color"]; ok || 5 != len(input.ExpressionAttributeNames) {
+		t.Errorf("expected #Color to be pruned: %v", input.ExpressionAttributeNames)
+	}
+}
+
+func TestRemoveAttributePath(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"Name":    &types.AttributeValueMemberS{Value: "Alice"},
+		"Score":   &types.AttributeValueMemberN{Value: "1"},
+		"Address": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"City": &types.AttributeValueMemberS{Value: "Seoul"}}},
+		"Items": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"Rank": &types.AttributeValueMemberN{Value: "2"}, "Name": &types.AttributeValueMemberS{Value: "pen"}}},
+		}},
+	}
+
+	for _, path := range []string{"Score", "Address.City", "Items[0].Rank", "Missing.Path", "Items[3]"} {
+		removeAttributePath(item, path)
+	}
+
+	if 2 != len(item) || nil == item["Name"] {
+		t.Fatalf("unexpected item: %v", item)
+	}
+	if items := item["Items"].(*types.AttributeValueMemberL).Value; 1 != len(items) || 1 != len(items[0].(*types.AttributeValueMemberM).Value) {
+		t.Errorf("unexpected items: %v", items)
+	}
+}
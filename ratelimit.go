@@ -0,0 +1,214 @@
+package ddb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type capacityKind int
+
+const (
+	capacityRead capacityKind = iota
+	capacityWrite
+)
+
+// RateLimiter paces calls with one token bucket for reads and one for writes,
+// refilled in capacity units per second. Each call reserves reservedUnits from
+// its bucket before it is let through, so that concurrent calls queue up, and
+// the capacity DynamoDB reports as consumed then replaces the reservation, so
+// expensive calls delay the following ones.
+type RateLimiter struct {
+	mu    sync.Mutex
+	read  tokenBucket
+	write tokenBucket
+	now   func() time.Time
+	sleep func(ctx context.Context, delay time.Duration) error
+}
+
+// reservedUnits is the estimate a call reserves before its consumed capacity
+// is known.
+const reservedUnits = 1
+
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter limits reads and writes to the given capacity units per
+// second. A rate of 0 leaves that kind of call unlimited.
+func NewRateLimiter(readCapacityUnits float64, writeCapacityUnits float64) *RateLimiter {
+	return &RateLimiter{
+		read:  tokenBucket{rate: readCapacityUnits, tokens: readCapacityUnits},
+		write: tokenBucket{rate: writeCapacityUnits, tokens: writeCapacityUnits},
+		now:   time.Now,
+		sleep: sleepContext,
+	}
+}
+
+// SetRateLimiter paces every call made by r with limiter. Calls then request
+// ReturnConsumedCapacity to feed the limiter.
+func (r *Ddb) SetRateLimiter(limiter *RateLimiter) *Ddb {
+	r.rateLimiter = limiter
+
+	return r
+}
+
+func (l *RateLimiter) bucket(kind capacityKind) *tokenBucket {
+	if capacityWrite == kind {
+		return &l.write
+	}
+
+	return &l.read
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+	}
+	b.last = now
+}
+
+// wait blocks until the bucket of kind holds the reservation of a call, and
+// takes it.
+func (l *RateLimiter) wait(ctx context.Context, kind capacityKind) (reserved float64, err error) {
+	for {
+		var delay time.Duration
+
+		l.mu.Lock()
+		bucket := l.bucket(kind)
+		if 0 < bucket.rate {
+			// a bucket smaller than a reservation never holds one
+			estimate := min(reservedUnits, bucket.rate)
+
+			bucket.refill(l.now())
+			if estimate > bucket.tokens {
+				delay = time.Duration((estimate - bucket.tokens) / bucket.rate * float64(time.Second))
+			} else {
+				bucket.tokens -= estimate
+				reserved = estimate
+			}
+		}
+		l.mu.Unlock()
+
+		if 0 == delay {
+			return
+		}
+
+		if err = l.sleep(ctx, delay); nil != err {
+			return
+		}
+	}
+}
+
+// consume replaces the reservation of a call by the units it consumed.
+func (l *RateLimiter) consume(kind capacityKind, reserved float64, units float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket := l.bucket(kind)
+	if 0 < bucket.rate {
+		bucket.refill(l.now())
+		bucket.tokens -= units - reserved
+	}
+}
+
+func capacityKindOf(input interface{}) capacityKind {
+	switch input.(type) {
+	case *dynamodb.PutItemInput, *dynamodb.UpdateItemInput, *dynamodb.DeleteItemInput, *dynamodb.BatchWriteItemInput, *dynamodb.TransactWriteItemsInput:
+		return capacityWrite
+	}
+
+	return capacityRead
+}
+
+// requestConsumedCapacity sets ReturnConsumedCapacity on inputs that support it,
+// keeping a mode already chosen by the caller.
+func requestConsumedCapacity(input interface{}, mode types.ReturnConsumedCapacity) {
+	var target *types.ReturnConsumedCapacity
+
+	switch in := input.(type) {
+	case *dynamodb.GetItemInput:
+		target = &in.ReturnConsumedCapacity
+	case *dynamodb.QueryInput:
+		target = &in.ReturnConsumedCapacity
+	case *dynamodb.ScanInput:
+		target = &in.ReturnConsumedCapacity
+	case *dynamodb.BatchGetItemInput:
+		target = &in.ReturnConsumedCapacity
+	case *dynamodb.PutItemInput:
+		target = &in.ReturnConsumedCapacity
+	case *dynamodb.UpdateItemInput:
+		target = &in.ReturnConsumedCapacity
+	case *dynamodb.DeleteItemInput:
+		target = &in.ReturnConsumedCapacity
+	case *dynamodb.BatchWriteItemInput:
+		target = &in.ReturnConsumedCapacity
+	case *dynamodb.TransactWriteItemsInput:
+		target = &in.ReturnConsumedCapacity
+	default:
+		return
+	}
+
+	if "" == *target || types.ReturnConsumedCapacityNone == *target {
+		*target = mode
+	}
+}
+
+func consumedCapacityOf(output interface{}) (consumed []types.ConsumedCapacity) {
+	switch out := output.(type) {
+	case *dynamodb.GetItemOutput:
+		consumed = capacityList(out.ConsumedCapacity)
+	case *dynamodb.QueryOutput:
+		consumed = capacityList(out.ConsumedCapacity)
+	case *dynamodb.ScanOutput:
+		consumed = capacityList(out.ConsumedCapacity)
+	case *dynamodb.BatchGetItemOutput:
+		consumed = out.ConsumedCapacity
+	case *dynamodb.PutItemOutput:
+		consumed = capacityList(out.ConsumedCapacity)
+	case *dynamodb.UpdateItemOutput:
+		consumed = capacityList(out.ConsumedCapacity)
+	case *dynamodb.DeleteItemOutput:
+		consumed = capacityList(out.ConsumedCapacity)
+	case *dynamodb.BatchWriteItemOutput:
+		consumed = out.ConsumedCapacity
+	case *dynamodb.TransactWriteItemsOutput:
+		consumed = out.ConsumedCapacity
+	}
+
+	return
+}
+
+func capacityList(consumed *types.ConsumedCapacity) []types.ConsumedCapacity {
+	if nil == consumed {
+		return nil
+	}
+
+	return []types.ConsumedCapacity{*consumed}
+}
+
+// capacityUnits sums the units of kind, falling back to the total when the
+// read/write split is not reported.
+func capacityUnits(kind capacityKind, consumed []types.ConsumedCapacity) (units float64) {
+	for _, c := range consumed {
+		switch {
+		case capacityRead == kind && nil != c.ReadCapacityUnits:
+			units += *c.ReadCapacityUnits
+		case capacityWrite == kind && nil != c.WriteCapacityUnits:
+			units += *c.WriteCapacityUnits
+		default:
+			units += aws.ToFloat64(c.CapacityUnits)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,103 @@
+package ddb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept time.Duration
+
+	limiter := NewRateLimiter(10, 0)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(ctx context.Context, delay time.Duration) error {
+		slept += delay
+		now = now.Add(delay)
+		return nil
+	}
+
+	reserved, err := limiter.wait(context.TODO(), capacityRead)
+	if nil != err || 0 != slept || 1 != reserved {
+		t.Fatalf("first call should reserve a unit without waiting, slept %s", slept)
+	}
+
+	consumed := consumedCapacityOf(&dynamodb.QueryOutput{ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(25)}})
+	limiter.consume(capacityRead, reserved, capacityUnits(capacityRead, consumed))
+
+	if _, err = limiter.wait(context.TODO(), capacityRead); nil != err {
+		t.Fatal(err)
+	}
+	// 15 units of debt plus one unit of headroom at 10 units per second
+	if 1600*time.Millisecond != slept {
+		t.Errorf("expected to wait 1.6s, waited %s", slept)
+	}
+
+	slept = 0
+	limiter.consume(capacityWrite, 0, 100)
+	if _, err = limiter.wait(context.TODO(), capacityWrite); nil != err || 0 != slept {
+		t.Errorf("unlimited writes should not wait, slept %s", slept)
+	}
+
+	input := &dynamodb.PutItemInput{}
+	requestConsumedCapacity(input, types.ReturnConsumedCapacityTotal)
+	if types.ReturnConsumedCapacityTotal != input.ReturnConsumedCapacity || capacityWrite != capacityKindOf(input) {
+		t.Errorf("unexpected input: %v", input.ReturnConsumedCapacity)
+	}
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Unix(0, 0)
+	now := start
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	limiter := NewRateLimiter(5, 0)
+	limiter.now = clock
+	limiter.sleep = func(ctx context.Context, delay time.Duration) error {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(delay)
+		return nil
+	}
+
+	var immediate atomic.Int32
+	query := func(ctx context.Context, input *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		if start.Equal(clock()) {
+			immediate.Add(1)
+		}
+		return &dynamodb.QueryOutput{ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(1)}}, nil
+	}
+
+	r := New(nil, "table").SetRateLimiter(limiter)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := invoke(r, &dynamodb.QueryInput{}, query); nil != err {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the 5 units of the bucket let 5 calls through, the other 15 need 3s of refill
+	if 5 < immediate.Load() {
+		t.Errorf("%d calls were let through at once", immediate.Load())
+	}
+	if elapsed := clock().Sub(start); 3*time.Second > elapsed {
+		t.Errorf("20 calls at 5 units per second took %s", elapsed)
+	}
+}
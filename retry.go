@@ -0,0 +1,291 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// RetryPolicy retries DynamoDB calls failing with a retryable error, waiting an
+// exponential backoff with full jitter between attempts.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Retryable classifies errors, IsRetryableError when nil.
+	Retryable func(err error) bool
+	// RetryNonIdempotent also retries the server errors of updates that are not
+	// idempotent, such as Fn:increase, which may have been applied already.
+	// Throttled calls are never applied and are retried either way.
+	RetryNonIdempotent bool
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Retryable:   IsRetryableError,
+	}
+}
+
+// SetRetryPolicy applies policy to every call made by r, including the
+// unprocessed keys of batch operations. Without a policy each call is made once.
+func (r *Ddb) SetRetryPolicy(policy RetryPolicy) *Ddb {
+	r.retryPolicy = &policy
+
+	return r
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if nil == p.Retryable {
+		return IsRetryableError(err)
+	}
+
+	return p.Retryable(err)
+}
+
+// backoff returns the delay before the given retry (0 based).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	var ceiling = p.BaseDelay
+
+	if 0 >= ceiling {
+		return 0
+	}
+
+	for i := 0; i < retry && (0 >= p.MaxDelay || ceiling < p.MaxDelay); i++ {
+		ceiling *= 2
+	}
+	if 0 < p.MaxDelay && ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// IsRetryableError reports throttling, transaction conflicts and transient
+// server errors.
+func IsRetryableError(err error) bool {
+	var throughputExceeded *types.ProvisionedThroughputExceededException
+	var requestLimitExceeded *types.RequestLimitExceeded
+	var transactionConflict *types.TransactionConflictException
+	var transactionCanceled *types.TransactionCanceledException
+	var internalServerError *types.InternalServerError
+	var apiError smithy.APIError
+
+	switch {
+	case nil == err:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &throughputExceeded), errors.As(err, &requestLimitExceeded), errors.As(err, &transactionConflict), errors.As(err, &internalServerError):
+		return true
+	case errors.As(err, &transactionCanceled):
+		for _, reason := range transactionCanceled.CancellationReasons {
+			switch aws.ToString(reason.Code) {
+			case "TransactionConflict", "ThrottlingError", "ProvisionedThroughputExceeded", "RequestLimitExceeded":
+				return true
+			}
+		}
+		return false
+	case errors.As(err, &apiError):
+		switch apiError.ErrorCode() {
+		case "ThrottlingException", "Throttling", "ServiceUnavailable", "InternalFailure":
+			return true
+		}
+	}
+
+	return false
+}
+
+// isServerError reports the errors after which a write may have been applied.
+func isServerError(err error) bool {
+	var internalServerError *types.InternalServerError
+	var apiError smithy.APIError
+
+	if errors.As(err, &internalServerError) {
+		return true
+	}
+	if errors.As(err, &apiError) {
+		switch apiError.ErrorCode() {
+		case "ServiceUnavailable", "InternalFailure":
+			return true
+		}
+	}
+
+	return false
+}
+
+var reNonIdempotentUpdate = regexp.MustCompile(`[+-]|list_append\(|(?i)(^|\s)ADD\s`)
+
+// isIdempotent is false for updates adding to a number, set or list, which
+// apply twice when repeated.
+func isIdempotent(input interface{}) bool {
+	if update, ok := input.(*dynamodb.UpdateItemInput); ok {
+		return !reNonIdempotentUpdate.MatchString(aws.ToString(update.UpdateExpression))
+	}
+
+	return true
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) (err error) {
+	if 0 >= delay {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+
+	return
+}
+
+// retry runs call until it succeeds, fails with a non retryable error or the
+// retry policy of r is exhausted. Server errors of a call that is not
+// idempotent are not retried unless the policy says so.
+func (r *Ddb) retry(ctx context.Context, idempotent bool, call func(ctx context.Context) error) (err error) {
+	var policy = RetryPolicy{MaxAttempts: 1}
+
+	if nil != r.retryPolicy {
+		policy = *r.retryPolicy
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = call(ctx)
+		if nil == err || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+			return
+		}
+		if !idempotent && !policy.RetryNonIdempotent && isServerError(err) {
+			return
+		}
+
+		if sleepErr := r.sleep(ctx, policy.backoff(attempt-1)); nil != sleepErr {
+			return
+		}
+	}
+}
+
+// unprocessedPolicy paces the resubmission of unprocessed batch items.
+func (r *Ddb) unprocessedPolicy() RetryPolicy {
+	if nil != r.retryPolicy {
+		return *r.retryPolicy
+	}
+
+	return DefaultRetryPolicy()
+}
+
+func (r *Ddb) sleep(ctx context.Context, delay time.Duration) error {
+	if nil != r.sleeper {
+		return r.sleeper(ctx, delay)
+	}
+
+	return sleepContext(ctx, delay)
+}
+
+// invoke sends input through call with the context, rate limiter, retry policy
+// and middlewares of r, and reports the consumed capacity.
+func invoke[I any, O any](r *Ddb, input *I, call func(context.Context, *I, ...func(*dynamodb.Options)) (*O, error)) (output *O, err error) {
+	var kind = capacityKindOf(input)
+	var operation = &Operation{
+		Name:      operationName(input),
+		Method:    r.callMethod(),
+		TableName: r.tableName,
+		IndexName: indexNameOf(input),
+		Input:     input,
+	}
+
+	if "" != r.returnConsumedCapacity {
+		requestConsumedCapacity(input, r.returnConsumedCapacity)
+	} else if nil != r.rateLimiter {
+		requestConsumedCapacity(input, types.ReturnConsumedCapacityTotal)
+	}
+
+	ctx := r.before(r.context(), operation)
+	start := time.Now()
+
+	err = r.retry(ctx, isIdempotent(input), func(ctx context.Context) (err error) {
+		var reserved float64
+
+		operation.Attempts++
+
+		if nil != r.rateLimiter {
+			// a failed call keeps its reservation, slowing down its retry
+			if reserved, err = r.rateLimiter.wait(ctx, kind); nil != err {
+				return
+			}
+		}
+
+		output, err = call(ctx, input)
+		if nil != err {
+			return
+		}
+
+		consumed := consumedCapacityOf(output)
+		operation.Capacity.add(consumed)
+		if nil != r.call {
+			r.call.Capacity.add(consumed)
+		}
+		r.reportCapacity(ctx, operation.Name, consumed)
+
+		if nil != r.rateLimiter {
+			units := capacityUnits(kind, consumed)
+			if 0 == units {
+				// nothing reported, count the call as one unit
+				units = 1
+			}
+			r.rateLimiter.consume(kind, reserved, units)
+		}
+
+		return
+	})
+
+	operation.Duration = time.Since(start)
+	if nil != r.call {
+		r.call.Operations++
+	}
+	operation.Err = err
+	if nil == err {
+		operation.Output = output
+	}
+	r.after(ctx, operation)
+
+	return
+}
+
+// WithContext returns a copy of r whose calls use ctx, typically for a single
+// request; r itself keeps its context.
+func (r *Ddb) WithContext(ctx context.Context) *Ddb {
+	var ddb = *r
+
+	ddb.ctx = ctx
+
+	return &ddb
+}
+
+func (r *Ddb) callMethod() string {
+	if nil == r.call {
+		return ""
+	}
+
+	return r.call.Method
+}
+
+func (r *Ddb) context() context.Context {
+	if nil == r.ctx {
+		return context.TODO()
+	}
+
+	return r.ctx
+}
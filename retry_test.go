@@ -0,0 +1,94 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestRetry(t *testing.T) {
+	var delays []time.Duration
+
+	r := New(nil, "table").SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+	r.sleeper = func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return nil
+	}
+
+	attempts := 0
+	err := r.retry(context.TODO(), true, func(ctx context.Context) error {
+		attempts++
+		return &types.ProvisionedThroughputExceededException{Message: aws.String("slow down")}
+	})
+	if 3 != attempts || nil == err || 2 != len(delays) {
+		t.Errorf("expected 3 attempts and 2 delays, got %d %d %v", attempts, len(delays), err)
+	}
+	for _, delay := range delays {
+		if 2*time.Millisecond < delay {
+			t.Errorf("delay %s exceeds MaxDelay", delay)
+		}
+	}
+
+	attempts = 0
+	err = r.retry(context.TODO(), true, func(ctx context.Context) error {
+		attempts++
+		return errors.New("validation")
+	})
+	if 1 != attempts || nil == err {
+		t.Errorf("expected a single attempt for non retryable error, got %d", attempts)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	r := New(nil, "table")
+
+	cases := []struct {
+		expression string
+		err        error
+		policy     RetryPolicy
+		attempts   int
+	}{
+		{"set #Score=if_not_exists(#Score, :_Zero) + :Score", &types.InternalServerError{}, RetryPolicy{MaxAttempts: 3}, 1},
+		{"set #Tags=list_append(#Tags, :Tags)", &types.InternalServerError{}, RetryPolicy{MaxAttempts: 3}, 1},
+		{"ADD #Count :one", &types.InternalServerError{}, RetryPolicy{MaxAttempts: 3}, 1},
+		{"set #Score=if_not_exists(#Score, :_Zero) + :Score", &types.InternalServerError{}, RetryPolicy{MaxAttempts: 3, RetryNonIdempotent: true}, 3},
+		{"set #Score=if_not_exists(#Score, :_Zero) + :Score", &types.ProvisionedThroughputExceededException{}, RetryPolicy{MaxAttempts: 3}, 3},
+		{"set #Name=:Name, #ADD=:ADD", &types.InternalServerError{}, RetryPolicy{MaxAttempts: 3}, 3},
+	}
+
+	for _, c := range cases {
+		attempts := 0
+		update := func(ctx context.Context, input *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			attempts++
+			return nil, c.err
+		}
+
+		_, _ = invoke(r.SetRetryPolicy(c.policy), &dynamodb.UpdateItemInput{UpdateExpression: aws.String(c.expression)}, update)
+		if c.attempts != attempts {
+			t.Errorf("%q failing with %T: expected %d attempts, got %d", c.expression, c.err, c.attempts, attempts)
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	cases := map[error]bool{
+		&types.RequestLimitExceeded{}:                                    true,
+		fmt.Errorf("wrapped: %w", &types.TransactionConflictException{}): true,
+		&types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("TransactionConflict")}}}: true,
+		&types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}}}:                          false,
+		&types.ConditionalCheckFailedException{}: false,
+		context.Canceled:                         false,
+	}
+
+	for err, expected := range cases {
+		if expected != IsRetryableError(err) {
+			t.Errorf("IsRetryableError(%v) should be %v", err, expected)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package ddb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrSchemaDrift = errors.New("table does not match the schema")
+
+// SchemaMismatch is one difference between the live table and the schema.
+// Index is empty for the base table.
+type SchemaMismatch struct {
+	Index    string `json:"index,omitempty"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (m SchemaMismatch) String() string {
+	var on = "table"
+
+	if "" != m.Index {
+		on = "index " + m.Index
+	}
+
+	return fmt.Sprintf("%s %s: expected %s, got %s", on, m.Field, m.Expected, m.Actual)
+}
+
+// SchemaReport is the result of CheckSchema.
+type SchemaReport struct {
+	TableName  string           `json:"tableName"`
+	Mismatches []SchemaMismatch `json:"mismatches"`
+}
+
+func (s SchemaReport) OK() bool {
+	return 0 == len(s.Mismatches)
+}
+
+func (s SchemaReport) String() string {
+	var lines = []string{fmt.Sprintf("%s: %d mismatch(es)", s.TableName, len(s.Mismatches))}
+
+	for _, mismatch := range s.Mismatches {
+		lines = append(lines, "  "+mismatch.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (s *SchemaReport) mismatch(index string, field string, expected string, actual string) {
+	if expected != actual {
+		s.Mismatches = append(s.Mismatches, SchemaMismatch{Index: index, Field: field, Expected: expected, Actual: actual})
+	}
+}
+
+// CheckSchema describes the table and compares its key schema, key attribute
+// types, indexes and their projections with the schema of r. The report lists
+// every mismatch, and err wraps ErrSchemaDrift when there is any.
+func (r *Ddb) CheckSchema() (report SchemaReport, err error) {
+	r, end := r.begin("CheckSchema")
+	defer func() { end(err) }()
+
+	var description *types.TableDescription
+
+	report.TableName = r.tableName
+
+	if description, err = r.DescribeTable(); nil != err {
+		return
+	}
+
+	report = compareSchema(r.tableName, r.schema(), description)
+	if !report.OK() {
+		err = fmt.Errorf("%w (%s)", ErrSchemaDrift, report)
+	}
+
+	return
+}
+
+func compareSchema(tableName string, schema TableSchema, description *types.TableDescription) (report SchemaReport) {
+	var expected = schema.CreateTableInput(tableName)
+	var attributeTypes = map[string]types.ScalarAttributeType{}
+	var indexes = map[string]types.GlobalSecondaryIndexDescription{}
+
+	report.TableName = tableName
+
+	for _, definition := range description.AttributeDefinitions {
+		attributeTypes[aws.ToString(definition.AttributeName)] = definition.AttributeType
+	}
+	for _, index := range description.GlobalSecondaryIndexes {
+		indexes[aws.ToString(index.IndexName)] = index
+	}
+
+	report.mismatch("", "status", string(types.TableStatusActive), string(description.TableStatus))
+	report.mismatch("", "key schema", formatKeySchema(expected.KeySchema), formatKeySchema(description.KeySchema))
+
+	for _, definition := range expected.AttributeDefinitions {
+		name := aws.ToString(definition.AttributeName)
+		actual, ok := attributeTypes[name]
+		if !ok {
+			// reported with the key schema or the index
+			continue
+		}
+		report.mismatch("", "attribute type of "+name, string(definition.AttributeType), string(actual))
+	}
+
+	for _, expectedIndex := range expected.GlobalSecondaryIndexes {
+		name := aws.ToString(expectedIndex.IndexName)
+		index, ok := indexes[name]
+		if !ok {
+			report.mismatch(name, "index", "present", "missing")
+			continue
+		}
+
+		var projection types.ProjectionType
+		if nil != index.Projection {
+			projection = index.Projection.ProjectionType
+		}
+
+		report.mismatch(name, "status", string(types.IndexStatusActive), string(index.IndexStatus))
+		report.mismatch(name, "key schema", formatKeySchema(expectedIndex.KeySchema), formatKeySchema(index.KeySchema))
+		report.mismatch(name, "projection", string(expectedIndex.Projection.ProjectionType), string(projection))
+	}
+
+	return
+}
+
+func formatKeySchema(elements []types.KeySchemaElement) string {
+	var keys []string
+
+	for _, element := range elements {
+		keys = append(keys, aws.ToString(element.AttributeName)+" "+string(element.KeyType))
+	}
+
+	if 0 == len(keys) {
+		return "none"
+	}
+
+	return strings.Join(keys, ", ")
+}
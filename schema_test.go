@@ -0,0 +1,68 @@
+package ddb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func describeInput(schema TableSchema) *types.TableDescription {
+	var input = schema.CreateTableInput("table")
+	var description = &types.TableDescription{
+		TableName:            input.TableName,
+		TableStatus:          types.TableStatusActive,
+		KeySchema:            input.KeySchema,
+		AttributeDefinitions: input.AttributeDefinitions,
+	}
+
+	for _, index := range input.GlobalSecondaryIndexes {
+		description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   index.IndexName,
+			IndexStatus: types.IndexStatusActive,
+			KeySchema:   index.KeySchema,
+			Projection:  index.Projection,
+		})
+	}
+
+	return description
+}
+
+func TestCompareSchema(t *testing.T) {
+	if report := compareSchema("table", DefaultTableSchema(), describeInput(DefaultTableSchema())); !report.OK() {
+		t.Errorf("expected no mismatch, got %s", report)
+	}
+
+	drifted := DefaultTableSchema()
+	drifted.Indexes = drifted.Indexes[:4]
+	drifted.Projection = types.ProjectionTypeKeysOnly
+	description := describeInput(drifted)
+	description.AttributeDefinitions[0].AttributeType = types.ScalarAttributeTypeN
+	description.GlobalSecondaryIndexes[1].KeySchema = []types.KeySchemaElement{{AttributeName: aws.String("GSI2PK"), KeyType: types.KeyTypeHash}}
+
+	report := compareSchema("table", DefaultTableSchema(), description)
+	expected := []SchemaMismatch{
+		{Field: "attribute type of PK", Expected: "S", Actual: "N"},
+		{Index: GSI1, Field: "projection", Expected: "ALL", Actual: "KEYS_ONLY"},
+		{Index: GSI2, Field: "key schema", Expected: "GSI2PK HASH, GSI2SK RANGE", Actual: "GSI2PK HASH"},
+		{Index: GSI2, Field: "projection", Expected: "ALL", Actual: "KEYS_ONLY"},
+		{Index: GSI3, Field: "projection", Expected: "ALL", Actual: "KEYS_ONLY"},
+		{Index: GSI4, Field: "projection", Expected: "ALL", Actual: "KEYS_ONLY"},
+		{Index: GSI5, Field: "index", Expected: "present", Actual: "missing"},
+	}
+	if len(expected) != len(report.Mismatches) {
+		t.Fatalf("unexpected report: %s", report)
+	}
+	for i, mismatch := range expected {
+		if mismatch != report.Mismatches[i] {
+			t.Errorf("mismatch %d: expected %s, got %s", i, mismatch, report.Mismatches[i])
+		}
+	}
+}
+
+func TestCheckSchemaRequiresTableClient(t *testing.T) {
+	if _, err := New(nil, "table").CheckSchema(); !errors.Is(err, ErrTableClientRequired) {
+		t.Errorf("expected ErrTableClientRequired, got %v", err)
+	}
+}
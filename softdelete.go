@@ -0,0 +1,277 @@
+package ddb
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/seill/util"
+)
+
+// DeletedAttribute is the attribute of DynamoDbMetaData.DeletedTimestamp.
+const DeletedAttribute = "DeletedTimestamp"
+
+// deletedKeyPrefix prefixes the index keys moved away by a soft delete.
+const deletedKeyPrefix = "Deleted"
+
+var ErrNotDeleted = errors.New("item is not deleted")
+
+type SoftDeletePolicy struct {
+	// MoveIndexKeys moves the GSI keys of a deleted item to Deleted<key>, so that
+	// the item drops out of the indexes until it is restored.
+	MoveIndexKeys bool
+}
+
+// SetSoftDelete turns DeleteItem into setting DeletedTimestamp. Reads then skip
+// deleted items unless IncludeDeleted is set, Restore undeletes them and Purge
+// deletes them for good.
+func (r *Ddb) SetSoftDelete(policy SoftDeletePolicy) *Ddb {
+	r.softDelete = &policy
+
+	return r
+}
+
+// indexKeys are the GSI key attributes of the table schema.
+func (r *Ddb) indexKeys() (keys []string) {
+	for _, indexName := range r.schema().Indexes {
+		keys = append(keys, indexName+"PK", indexName+"SK")
+	}
+
+	return
+}
+
+func (r *Ddb) softDeleteItem(key Key) (err error) {
+	var input *dynamodb.UpdateItemInput
+	var moved map[string]types.AttributeValue
+	var conditionFailed *types.ConditionalCheckFailedException
+
+	if r.softDelete.MoveIndexKeys {
+		var item map[string]types.AttributeValue
+
+		item, err = r.GetItem(key, GetOption{Projection: append(r.indexKeys(), DeletedAttribute), ConsistentRead: true, IncludeDeleted: true})
+		if errors.Is(err, ErrItemNotFound) {
+			// deleting a missing item is not an error
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		if _, deleted := item[DeletedAttribute]; deleted {
+			return
+		}
+
+		moved = map[string]types.AttributeValue{}
+		for _, indexKey := range r.indexKeys() {
+			if v, ok := item[indexKey]; ok {
+				moved[indexKey] = v
+			}
+		}
+	}
+
+	input, err = r.buildSoftDeleteInput(key, moved)
+	if err != nil {
+		return
+	}
+
+	_, err = invoke(r, input, r.dynamoDb.UpdateItem)
+	if 0 == len(moved) && errors.As(err, &conditionFailed) {
+		// the item is missing or already deleted
+		err = nil
+	}
+
+	return
+}
+
+// buildSoftDeleteInput sets DeletedTimestamp on an existing item that is not
+// deleted yet, moving the moved index keys unless they changed meanwhile.
+func (r *Ddb) buildSoftDeleteInput(key Key, moved map[string]types.AttributeValue) (input *dynamodb.UpdateItemInput, err error) {
+	var keyAv map[string]types.AttributeValue
+	var now types.AttributeValue
+	var sets = []string{"#DeletedTimestamp = :now", "#UpdatedTimestamp = :now"}
+	var removes []string
+	var conditions = []string{"attribute_exists(#PK)", "attribute_not_exists(#DeletedTimestamp)"}
+	var names = map[string]string{"#PK": "PK", "#DeletedTimestamp": DeletedAttribute, "#UpdatedTimestamp": "UpdatedTimestamp"}
+	var values = map[string]types.AttributeValue{}
+
+	if keyAv, err = attributevalue.MarshalMap(key); err != nil {
+		return
+	}
+	if now, err = attributevalue.Marshal(r.now()); err != nil {
+		return
+	}
+	values[":now"] = now
+
+	for _, indexKey := range sortedMapKeys(moved) {
+		names["#"+indexKey] = indexKey
+		names["#"+deletedKeyPrefix+indexKey] = deletedKeyPrefix + indexKey
+		values[":"+indexKey] = moved[indexKey]
+		sets = append(sets, fmt.Sprintf("#%s%s = :%s", deletedKeyPrefix, indexKey, indexKey))
+		removes = append(removes, "#"+indexKey)
+		conditions = append(conditions, fmt.Sprintf("#%s = :%s", indexKey, indexKey))
+	}
+
+	input = &dynamodb.UpdateItemInput{
+		Key:                       keyAv,
+		TableName:                 aws.String(r.tableName),
+		UpdateExpression:          aws.String(updateExpression(sets, removes)),
+		ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	return
+}
+
+// Restore undeletes a soft deleted item, moving its index keys back.
+func (r *Ddb) Restore(key Key) (err error) {
+	r, end := r.begin("Restore")
+	defer func() { end(err) }()
+
+	var item map[string]types.AttributeValue
+	var input *dynamodb.UpdateItemInput
+
+	item, err = r.GetItem(key, GetOption{Projection: append(r.deletedIndexKeys(), DeletedAttribute), ConsistentRead: true, IncludeDeleted: true})
+	if err != nil {
+		return
+	}
+	if _, deleted := item[DeletedAttribute]; !deleted {
+		err = fmt.Errorf("%w (%s)", ErrNotDeleted, util.StructToString(key))
+		return
+	}
+
+	input, err = r.buildRestoreInput(key, item)
+	if err != nil {
+		return
+	}
+
+	_, err = invoke(r, input, r.dynamoDb.UpdateItem)
+
+	return
+}
+
+func (r *Ddb) deletedIndexKeys() (keys []string) {
+	for _, indexKey := range r.indexKeys() {
+		keys = append(keys, deletedKeyPrefix+indexKey)
+	}
+
+	return
+}
+
+func (r *Ddb) buildRestoreInput(key Key, item map[string]types.AttributeValue) (input *dynamodb.UpdateItemInput, err error) {
+	var keyAv map[string]types.AttributeValue
+	var now types.AttributeValue
+	var sets = []string{"#UpdatedTimestamp = :now"}
+	var removes = []string{"#DeletedTimestamp"}
+	var names = map[string]string{"#DeletedTimestamp": DeletedAttribute, "#UpdatedTimestamp": "UpdatedTimestamp"}
+	var values = map[string]types.AttributeValue{}
+
+	if keyAv, err = attributevalue.MarshalMap(key); err != nil {
+		return
+	}
+	if now, err = attributevalue.Marshal(r.now()); err != nil {
+		return
+	}
+	values[":now"] = now
+
+	for _, indexKey := range r.indexKeys() {
+		if _, ok := item[deletedKeyPrefix+indexKey]; !ok {
+			continue
+		}
+		names["#"+indexKey] = indexKey
+		names["#"+deletedKeyPrefix+indexKey] = deletedKeyPrefix + indexKey
+		sets = append(sets, fmt.Sprintf("#%s = #%s%s", indexKey, deletedKeyPrefix, indexKey))
+		removes = append(removes, "#"+deletedKeyPrefix+indexKey)
+	}
+
+	input = &dynamodb.UpdateItemInput{
+		Key:                       keyAv,
+		TableName:                 aws.String(r.tableName),
+		UpdateExpression:          aws.String(updateExpression(sets, removes)),
+		ConditionExpression:       aws.String("attribute_exists(#DeletedTimestamp)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	return
+}
+
+func updateExpression(sets []string, removes []string) (expression string) {
+	expression = "SET " + strings.Join(sets, ", ")
+	if 0 < len(removes) {
+		expression += " REMOVE " + strings.Join(removes, ", ")
+	}
+
+	return
+}
+
+// Purge deletes an item for good, whether it is soft deleted or not.
+func (r *Ddb) Purge(key Key) (err error) {
+	r, end := r.begin("Purge")
+	defer func() { end(err) }()
+
+	var input *dynamodb.DeleteItemInput
+
+	input, err = r.buildDeleteItemInput(key)
+	if err != nil {
+		return
+	}
+
+	_, err = invoke(r, input, r.dynamoDb.DeleteItem)
+
+	return
+}
+
+// undeletedFilter adds the condition hiding soft deleted items to filterExpression.
+func (r *Ddb) undeletedFilter(filterExpression string, includeDeleted bool, expressionAttributeNames map[string]string) string {
+	var condition = fmt.Sprintf("attribute_not_exists(#%s)", DeletedAttribute)
+
+	if nil == r.softDelete || includeDeleted {
+		return filterExpression
+	}
+
+	expressionAttributeNames["#"+DeletedAttribute] = DeletedAttribute
+
+	if "" == filterExpression {
+		return condition
+	}
+
+	return fmt.Sprintf("(%s) AND %s", filterExpression, condition)
+}
+
+// deletionProjection adds DeletedTimestamp to projection so that soft deleted
+// items can be recognised once read.
+func (r *Ddb) deletionProjection(projection []string, includeDeleted bool) []string {
+	if nil == r.softDelete || includeDeleted || 0 == len(projection) || slices.Contains(projection, DeletedAttribute) {
+		return projection
+	}
+
+	return append(slices.Clone(projection), DeletedAttribute)
+}
+
+// undeleted drops soft deleted items, and DeletedTimestamp from the others when
+// only deletionProjection asked for it.
+func (r *Ddb) undeleted(items []map[string]types.AttributeValue, projection []string, includeDeleted bool) (kept []map[string]types.AttributeValue) {
+	var added = 0 < len(projection) && !slices.Contains(projection, DeletedAttribute)
+
+	if nil == r.softDelete || includeDeleted {
+		return items
+	}
+
+	for _, item := range items {
+		if _, deleted := item[DeletedAttribute]; deleted {
+			continue
+		}
+		if added {
+			delete(item, DeletedAttribute)
+		}
+		kept = append(kept, item)
+	}
+
+	return
+}
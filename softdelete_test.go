@@ -0,0 +1,42 @@
+package ddb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestSoftDeleteInput(t *testing.T) {
+	r := New(nil, "table").SetClock(func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) })
+	key := Key{PK: aws.String("USER#1"), SK: aws.String("PROFILE")}
+
+	if explanation, err := r.ExplainDeleteItem(key); nil != err || "DeleteItem" != explanation.Operation {
+		t.Fatalf("unexpected explanation: %v %v", explanation, err)
+	}
+
+	r.SetSoftDelete(SoftDeletePolicy{MoveIndexKeys: true})
+	input, err := r.buildSoftDeleteInput(key, map[string]types.AttributeValue{"GSI1PK": &types.AttributeValueMemberS{Value: "USER"}})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "SET #DeletedTimestamp = :now, #UpdatedTimestamp = :now, #DeletedGSI1PK = :GSI1PK REMOVE #GSI1PK" != *input.UpdateExpression ||
+		"attribute_exists(#PK) AND attribute_not_exists(#DeletedTimestamp) AND #GSI1PK = :GSI1PK" != *input.ConditionExpression {
+		t.Errorf("unexpected soft delete: %s / %s", *input.UpdateExpression, *input.ConditionExpression)
+	}
+
+	explanation, err := r.ExplainDeleteItem(key)
+	if nil != err || 1 != len(explanation.Notes) {
+		t.Fatalf("unexpected explanation: %v %v", explanation, err)
+	}
+	if update := explanation.Input.(*dynamodb.UpdateItemInput); "SET #DeletedTimestamp = :now, #UpdatedTimestamp = :now" != *update.UpdateExpression {
+		t.Errorf("unexpected update: %s", *update.UpdateExpression)
+	}
+
+	query, err := r.buildQueryInput(Key{PK: aws.String("USER"), IndexName: aws.String(GSI1)}, "", QueryOption{})
+	if nil != err || "attribute_not_exists(#DeletedTimestamp)" != aws.ToString(query.FilterExpression) {
+		t.Errorf("unexpected filter: %v %v", aws.ToString(query.FilterExpression), err)
+	}
+}
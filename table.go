@@ -0,0 +1,267 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrTableClientRequired = errors.New("client does not support table operations")
+
+// TableClient is the part of the DynamoDB API used to manage the table.
+// *dynamodb.Client satisfies it; table methods fail with ErrTableClientRequired
+// when the Client given to New does not.
+type TableClient interface {
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+}
+
+var _ TableClient = (*dynamodb.Client)(nil)
+
+// TableSchema is the layout of the table: a PK/SK string key and, for each
+// index, a GSI keyed by <index>PK/<index>SK.
+type TableSchema struct {
+	Indexes []string `json:"indexes"`
+	// Projection of every index, ALL when empty.
+	Projection         types.ProjectionType `json:"projection"`
+	BillingMode        types.BillingMode    `json:"billingMode"`
+	ReadCapacityUnits  int64                `json:"readCapacityUnits"`
+	WriteCapacityUnits int64                `json:"writeCapacityUnits"`
+	// TTLAttribute is enabled as the time to live attribute by SetupTable when set.
+	TTLAttribute string `json:"ttlAttribute"`
+}
+
+// DefaultTableSchema is the layout implied by DynamoDbMetaData, billed per request.
+func DefaultTableSchema() TableSchema {
+	return TableSchema{
+		Indexes:      []string{GSI1, GSI2, GSI3, GSI4, GSI5},
+		Projection:   types.ProjectionTypeAll,
+		BillingMode:  types.BillingModePayPerRequest,
+		TTLAttribute: TTLAttribute,
+	}
+}
+
+// SetTableSchema replaces DefaultTableSchema for the table methods of r.
+func (r *Ddb) SetTableSchema(schema TableSchema) *Ddb {
+	r.tableSchema = &schema
+
+	return r
+}
+
+func (r *Ddb) schema() TableSchema {
+	if nil == r.tableSchema {
+		return DefaultTableSchema()
+	}
+
+	return *r.tableSchema
+}
+
+// CreateTableInput builds the request creating tableName with schema s.
+func (s TableSchema) CreateTableInput(tableName string) *dynamodb.CreateTableInput {
+	var projection = s.Projection
+	var input = &dynamodb.CreateTableInput{
+		TableName:   aws.String(tableName),
+		BillingMode: s.BillingMode,
+		KeySchema:   keySchema(""),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("SK"), AttributeType: types.ScalarAttributeTypeS},
+		},
+	}
+
+	if "" == projection {
+		projection = types.ProjectionTypeAll
+	}
+
+	if types.BillingModeProvisioned == s.BillingMode {
+		input.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(s.ReadCapacityUnits),
+			WriteCapacityUnits: aws.Int64(s.WriteCapacityUnits),
+		}
+	}
+
+	for _, indexName := range s.Indexes {
+		index := types.GlobalSecondaryIndex{
+			IndexName:  aws.String(indexName),
+			KeySchema:  keySchema(indexName),
+			Projection: &types.Projection{ProjectionType: projection},
+		}
+		if nil != input.ProvisionedThroughput {
+			index.ProvisionedThroughput = input.ProvisionedThroughput
+		}
+
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, index)
+		input.AttributeDefinitions = append(input.AttributeDefinitions,
+			types.AttributeDefinition{AttributeName: aws.String(indexName + "PK"), AttributeType: types.ScalarAttributeTypeS},
+			types.AttributeDefinition{AttributeName: aws.String(indexName + "SK"), AttributeType: types.ScalarAttributeTypeS},
+		)
+	}
+
+	return input
+}
+
+func keySchema(indexName string) []types.KeySchemaElement {
+	return []types.KeySchemaElement{
+		{AttributeName: aws.String(indexName + "PK"), KeyType: types.KeyTypeHash},
+		{AttributeName: aws.String(indexName + "SK"), KeyType: types.KeyTypeRange},
+	}
+}
+
+func (r *Ddb) tableClient() (client TableClient, err error) {
+	var ok bool
+
+	if client, ok = r.dynamoDb.(TableClient); !ok {
+		err = fmt.Errorf("%w (%T)", ErrTableClientRequired, r.dynamoDb)
+	}
+
+	return
+}
+
+// CreateTable creates the table with the schema of r, without waiting for it
+// to be ACTIVE.
+func (r *Ddb) CreateTable() (description *types.TableDescription, err error) {
+	r, end := r.begin("CreateTable")
+	defer func() { end(err) }()
+
+	var client TableClient
+	var output *dynamodb.CreateTableOutput
+
+	if client, err = r.tableClient(); nil != err {
+		return
+	}
+
+	output, err = invoke(r, r.schema().CreateTableInput(r.tableName), client.CreateTable)
+	if nil != err {
+		return
+	}
+
+	description = output.TableDescription
+
+	return
+}
+
+func (r *Ddb) DescribeTable() (description *types.TableDescription, err error) {
+	r, end := r.begin("DescribeTable")
+	defer func() { end(err) }()
+
+	var client TableClient
+	var output *dynamodb.DescribeTableOutput
+
+	if client, err = r.tableClient(); nil != err {
+		return
+	}
+
+	output, err = invoke(r, &dynamodb.DescribeTableInput{TableName: aws.String(r.tableName)}, client.DescribeTable)
+	if nil != err {
+		return
+	}
+
+	description = output.Table
+
+	return
+}
+
+// WaitUntilActive waits up to maxWait for the table to exist and be ACTIVE.
+func (r *Ddb) WaitUntilActive(maxWait time.Duration, optFns ...func(*dynamodb.TableExistsWaiterOptions)) (err error) {
+	r, end := r.begin("WaitUntilActive")
+	defer func() { end(err) }()
+
+	var client TableClient
+
+	if client, err = r.tableClient(); nil != err {
+		return
+	}
+
+	return dynamodb.NewTableExistsWaiter(client, optFns...).Wait(r.context(), &dynamodb.DescribeTableInput{TableName: aws.String(r.tableName)}, maxWait)
+}
+
+// EnableTTL makes attribute, a unix time in seconds, the time to live of items.
+// Nothing is done when attribute is already enabled.
+func (r *Ddb) EnableTTL(attribute string) (err error) {
+	r, end := r.begin("EnableTTL")
+	defer func() { end(err) }()
+
+	var client TableClient
+	var described *dynamodb.DescribeTimeToLiveOutput
+
+	if client, err = r.tableClient(); nil != err {
+		return
+	}
+
+	described, err = invoke(r, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(r.tableName)}, client.DescribeTimeToLive)
+	if nil != err {
+		return
+	}
+
+	if description := described.TimeToLiveDescription; nil != description {
+		switch description.TimeToLiveStatus {
+		case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+			if attribute == aws.ToString(description.AttributeName) {
+				return
+			}
+			err = fmt.Errorf("time to live is already enabled on %s", aws.ToString(description.AttributeName))
+			return
+		}
+	}
+
+	_, err = invoke(r, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(r.tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attribute),
+			Enabled:       aws.Bool(true),
+		},
+	}, client.UpdateTimeToLive)
+
+	return
+}
+
+// SetupTable creates the table unless it exists, waits up to maxWait for it
+// to be ACTIVE and enables the TTLAttribute of the schema.
+func (r *Ddb) SetupTable(maxWait time.Duration) (err error) {
+	r, end := r.begin("SetupTable")
+	defer func() { end(err) }()
+
+	var inUse *types.ResourceInUseException
+
+	if _, err = r.CreateTable(); nil != err && !errors.As(err, &inUse) {
+		return
+	}
+
+	if err = r.WaitUntilActive(maxWait); nil != err {
+		return
+	}
+
+	if attribute := r.schema().TTLAttribute; "" != attribute {
+		err = r.EnableTTL(attribute)
+	}
+
+	return
+}
+
+// DeleteTable deletes the table and waits up to maxWait for it to be gone; a
+// zero maxWait returns once the deletion is accepted.
+func (r *Ddb) DeleteTable(maxWait time.Duration) (err error) {
+	r, end := r.begin("DeleteTable")
+	defer func() { end(err) }()
+
+	var client TableClient
+
+	if client, err = r.tableClient(); nil != err {
+		return
+	}
+
+	_, err = invoke(r, &dynamodb.DeleteTableInput{TableName: aws.String(r.tableName)}, client.DeleteTable)
+	if nil != err || 0 >= maxWait {
+		return
+	}
+
+	return dynamodb.NewTableNotExistsWaiter(client).Wait(r.context(), &dynamodb.DescribeTableInput{TableName: aws.String(r.tableName)}, maxWait)
+}
@@ -0,0 +1,94 @@
+package ddb
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TTLAttribute is the attribute of DynamoDbMetaData.ExpiresAt, in epoch seconds.
+const TTLAttribute = "ExpiresAt"
+
+type WriteOption struct {
+	// TTL sets ExpiresAt to now + TTL when positive.
+	TTL time.Duration `json:"ttl"`
+}
+
+func mergeWriteOption(writeOption []WriteOption) (option WriteOption) {
+	for _, o := range writeOption {
+		if 0 < o.TTL {
+			option.TTL = o.TTL
+		}
+	}
+
+	return
+}
+
+// SetHideExpired hides items whose ExpiresAt is past but that DynamoDB has not
+// deleted yet, which can take days. Queries and scans filter them out and
+// GetItem reports them as not found.
+func (r *Ddb) SetHideExpired(hide bool) *Ddb {
+	r.hideExpired = hide
+
+	return r
+}
+
+func (r *Ddb) expiresAt(ttl time.Duration) *types.AttributeValueMemberN {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(r.now().Add(ttl).Unix(), 10)}
+}
+
+// unexpiredFilter adds the condition hiding expired items to filterExpression.
+func (r *Ddb) unexpiredFilter(filterExpression string, expressionAttributeNames map[string]string, expressionAttributeValues map[string]types.AttributeValue) string {
+	var condition = fmt.Sprintf("(attribute_not_exists(#%s) OR #%s > :_now)", TTLAttribute, TTLAttribute)
+
+	if !r.hideExpired {
+		return filterExpression
+	}
+
+	expressionAttributeNames["#"+TTLAttribute] = TTLAttribute
+	expressionAttributeValues[":_now"] = r.expiresAt(0)
+
+	if "" == filterExpression {
+		return condition
+	}
+
+	return fmt.Sprintf("(%s) AND %s", filterExpression, condition)
+}
+
+// expiryProjection adds ExpiresAt to projection so that expired items can be
+// recognised once read.
+func (r *Ddb) expiryProjection(projection []string) []string {
+	if !r.hideExpired || 0 == len(projection) || slices.Contains(projection, TTLAttribute) {
+		return projection
+	}
+
+	return append(slices.Clone(projection), TTLAttribute)
+}
+
+// unexpired drops expired items, and ExpiresAt from the others when only
+// expiryProjection asked for it.
+func (r *Ddb) unexpired(items []map[string]types.AttributeValue, projection []string) (kept []map[string]types.AttributeValue) {
+	var now = r.now().Unix()
+	var added = 0 < len(projection) && !slices.Contains(projection, TTLAttribute)
+
+	if !r.hideExpired {
+		return items
+	}
+
+	for _, item := range items {
+		if n, ok := item[TTLAttribute].(*types.AttributeValueMemberN); ok {
+			if expiresAt, err := strconv.ParseInt(n.Value, 10, 64); nil == err && expiresAt <= now {
+				continue
+			}
+		}
+		if added {
+			delete(item, TTLAttribute)
+		}
+		kept = append(kept, item)
+	}
+
+	return
+}
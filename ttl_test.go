@@ -0,0 +1,33 @@
+package ddb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestUnexpiredFilter(t *testing.T) {
+	r := New(nil, "table").SetClock(func() time.Time { return time.Unix(1700000000, 0) })
+
+	where := Equal("Status", "active")
+	input, err := r.buildQueryInput(Key{PK: aws.String("USER")}, "", QueryOption{Where: &where})
+	if nil != err || "#Status = :_f0" != aws.ToString(input.FilterExpression) {
+		t.Fatalf("unexpected filter: %v %v", input.FilterExpression, err)
+	}
+
+	r.SetHideExpired(true)
+	input, err = r.buildQueryInput(Key{PK: aws.String("USER")}, "", QueryOption{Where: &where})
+	if nil != err || "(#Status = :_f0) AND (attribute_not_exists(#ExpiresAt) OR #ExpiresAt > :_now)" != *input.FilterExpression {
+		t.Fatalf("unexpected filter: %v %v", aws.ToString(input.FilterExpression), err)
+	}
+	if now := input.ExpressionAttributeValues[":_now"].(*types.AttributeValueMemberN).Value; "1700000000" != now {
+		t.Errorf("unexpected now: %s", now)
+	}
+
+	put, err := r.buildPutItemInput(DynamoDbMetaData{PK: "USER#1", SK: "PROFILE"}, WriteOption{TTL: time.Minute})
+	if nil != err || "1700000060" != put.Item[TTLAttribute].(*types.AttributeValueMemberN).Value {
+		t.Errorf("unexpected item: %v %v", put.Item, err)
+	}
+}
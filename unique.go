@@ -0,0 +1,151 @@
+package ddb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/seill/util"
+)
+
+var ErrAmbiguousItem = errors.New("more than one item matches")
+
+// AmbiguousItemError is returned by GetUniqueItem when the key matches several
+// items. Items holds the first two matches.
+type AmbiguousItemError struct {
+	Key   Key
+	Items []map[string]types.AttributeValue
+}
+
+func (e *AmbiguousItemError) Error() string {
+	return fmt.Sprintf("%s (%s)", ErrAmbiguousItem.Error(), util.StructToString(e.Key))
+}
+
+func (e *AmbiguousItemError) Is(target error) bool {
+	return target == ErrAmbiguousItem
+}
+
+// GetUniqueItem returns the only item matching key, typically on a GSI whose
+// keys are expected to be unique, and fails with *AmbiguousItemError otherwise.
+func (r *Ddb) GetUniqueItem(key Key, getOption ...GetOption) (item map[string]types.AttributeValue, err error) {
+	r, end := r.begin("GetUniqueItem")
+	defer func() { end(err) }()
+
+	var option = mergeGetOption(getOption)
+	var input *dynamodb.QueryInput
+	var items []map[string]types.AttributeValue
+	var projection = option.Projection
+
+	if option.FetchFromBase && nil != key.IndexName {
+		projection = baseKeyProjection
+	}
+
+	input, err = r.buildQueryInput(key, "", QueryOption{Projection: projection, ConsistentRead: option.ConsistentRead, IncludeDeleted: option.IncludeDeleted})
+	if err != nil {
+		return
+	}
+
+	input.Limit = aws.Int32(2)
+
+	for {
+		var output *dynamodb.QueryOutput
+
+		output, err = invoke(r, input, r.dynamoDb.Query)
+		if err != nil {
+			return
+		}
+
+		items = append(items, output.Items...)
+		if 1 < len(items) {
+			err = &AmbiguousItemError{Key: key, Items: items[:2]}
+			return
+		}
+
+		if nil == output.LastEvaluatedKey {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+
+	if 0 == len(items) {
+		err = fmt.Errorf("%w (%s)", ErrItemNotFound, util.StructToString(key))
+		return
+	}
+
+	item = items[0]
+
+	if option.FetchFromBase && nil != key.IndexName {
+		item, err = r.GetItem(baseKeyOf(item), GetOption{Projection: option.Projection, IncludeDeleted: option.IncludeDeleted})
+	}
+
+	return
+}
+
+// GetFirstItem returns the first item matching key and the filters of
+// queryOption in an explicit order, given by Order or ScanIndexForward.
+func (r *Ddb) GetFirstItem(key Key, queryOption QueryOption) (item map[string]types.AttributeValue, err error) {
+	r, end := r.begin("GetFirstItem")
+	defer func() { end(err) }()
+
+	var input *dynamodb.QueryInput
+	var inMemorySort bool
+
+	if 0 == len(queryOption.Order) && nil == queryOption.ScanIndexForward {
+		err = fmt.Errorf("%w: GetFirstItem requires Order or ScanIndexForward", ErrOrderNotSupported)
+		return
+	}
+
+	queryOption, inMemorySort, err = r.resolveOrder(key, queryOption)
+	if err != nil {
+		return
+	}
+
+	queryOption.Page = nil
+
+	input, err = r.buildQueryInput(key, "", queryOption)
+	if err != nil {
+		return
+	}
+
+	if inMemorySort {
+		var items []map[string]types.AttributeValue
+
+		items, err = r.queryAndSort(input, queryOption.Order)
+		if err != nil {
+			return
+		}
+		if 0 < len(items) {
+			item = items[0]
+			return
+		}
+	} else {
+		if nil == input.FilterExpression {
+			input.Limit = aws.Int32(1)
+		}
+
+		for {
+			var output *dynamodb.QueryOutput
+
+			output, err = invoke(r, input, r.dynamoDb.Query)
+			if err != nil {
+				return
+			}
+
+			if 0 < len(output.Items) {
+				item = output.Items[0]
+				return
+			}
+
+			if nil == output.LastEvaluatedKey {
+				break
+			}
+			input.ExclusiveStartKey = output.LastEvaluatedKey
+		}
+	}
+
+	err = fmt.Errorf("%w (%s)", ErrItemNotFound, util.StructToString(key))
+
+	return
+}
@@ -0,0 +1,158 @@
+package ddb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type FilterProblem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// FilterValidationError lists every invalid entry found in a QueryOption.
+type FilterValidationError struct {
+	Problems []FilterProblem `json:"problems"`
+}
+
+func (e *FilterValidationError) Error() string {
+	var messages = make([]string, len(e.Problems))
+
+	for i, problem := range e.Problems {
+		messages[i] = fmt.Sprintf("%s: %s", problem.Path, problem.Message)
+	}
+
+	return fmt.Sprintf("invalid query option (%s)", strings.Join(messages, "; "))
+}
+
+func (e *FilterValidationError) add(path string, format string, a ...interface{}) {
+	e.Problems = append(e.Problems, FilterProblem{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+// ValidateQueryOption checks the filters of a QueryOption without building any expression.
+func ValidateQueryOption(queryOption QueryOption) (err error) {
+	var validationError = &FilterValidationError{}
+
+	parseLegacyFilter(queryOption.Filter, validationError)
+
+	if nil != queryOption.Where {
+		validateFilterExpression("where", *queryOption.Where, validationError)
+	}
+
+	if 0 < len(validationError.Problems) {
+		err = validationError
+	}
+
+	return
+}
+
+type legacyFilter struct {
+	FilterExpression
+	condition string
+}
+
+// parseLegacyFilter converts a QueryOption.Filter map into conditions, parent
+// conditions first and child conditions (with "position") after, both in key order.
+func parseLegacyFilter(filters map[string]interface{}, validationError *FilterValidationError) (conditions []legacyFilter) {
+	var keys = make([]string, 0, len(filters))
+	var parents, children []legacyFilter
+
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var path = "filter." + k
+		var filter legacyFilter
+		var ok bool
+
+		item, isMap := filters[k].(map[string]interface{})
+		if !isMap {
+			validationError.add(path, "must be an object")
+			continue
+		}
+
+		if filter.Field, ok = item["field"].(string); !ok || "" == filter.Field {
+			validationError.add(path+".field", "required string")
+		}
+		if filter.Type, ok = item["type"].(string); !ok || "" == filter.Type {
+			validationError.add(path+".type", "required string")
+		}
+		if nil != item["valueType"] {
+			if filter.ValueType, ok = item["valueType"].(string); !ok {
+				validationError.add(path+".valueType", "must be a string")
+			}
+		}
+		if nil != item["condition"] {
+			condition, _ := item["condition"].(string)
+			filter.condition = strings.ToUpper(condition)
+			if "AND" != filter.condition && "OR" != filter.condition {
+				validationError.add(path+".condition", "must be AND or OR")
+			}
+		}
+		filter.Keyword = item["keyword"]
+
+		if _, present := item["keyword"]; !present && !filterTypeWithoutKeyword(filter.Type) {
+			validationError.add(path+".keyword", "required for type %s", filter.Type)
+		} else if "" != filter.Field && "" != filter.Type {
+			validateFilterCondition(path, filter.FilterExpression, validationError)
+		}
+
+		if nil == item["position"] {
+			parents = append(parents, filter)
+		} else {
+			children = append(children, filter)
+		}
+	}
+
+	conditions = append(parents, children...)
+
+	return
+}
+
+func validateFilterExpression(path string, filter FilterExpression, validationError *FilterValidationError) {
+	var kinds = 0
+
+	if 0 < len(filter.And) {
+		kinds++
+		for i, child := range filter.And {
+			validateFilterExpression(fmt.Sprintf("%s.and[%d]", path, i), child, validationError)
+		}
+	}
+	if 0 < len(filter.Or) {
+		kinds++
+		for i, child := range filter.Or {
+			validateFilterExpression(fmt.Sprintf("%s.or[%d]", path, i), child, validationError)
+		}
+	}
+	if nil != filter.Not {
+		kinds++
+		validateFilterExpression(path+".not", *filter.Not, validationError)
+	}
+	if "" != filter.Field {
+		kinds++
+		validateFilterCondition(path, filter, validationError)
+	}
+
+	if 1 != kinds {
+		validationError.add(path, "must set exactly one of and, or, not, field")
+	}
+}
+
+// validateFilterCondition renders the condition against scratch maps so that
+// type and value checks are shared with the expression builder.
+func validateFilterCondition(path string, filter FilterExpression, validationError *FilterValidationError) {
+	var scratch = newExpressionBuilder(map[string]string{}, map[string]types.AttributeValue{})
+
+	if _, err := filter.renderCondition(scratch); nil != err {
+		validationError.add(path, "%s", err.Error())
+	}
+}
+
+func filterTypeWithoutKeyword(filterType string) bool {
+	return "exists" == filterType || "not_exists" == filterType
+}